@@ -0,0 +1,183 @@
+package chromedp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/knq/chromedp/cdp"
+	"github.com/knq/chromedp/cdp/accessibility"
+	"github.com/knq/chromedp/cdp/dom"
+)
+
+// ByRole is a query option to select a single element by its ARIA role
+// (whether explicit or implicit), as computed by the browser's accessibility
+// tree. The role can be further restricted to an accessible name with AXName.
+//
+// For example, the button named Save:
+//
+//	Click("button", ByRole, AXName("Save"))
+func ByRole(s *Selector) {
+	ByFunc(func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) ([]cdp.NodeID, error) {
+		role := s.selAsString()
+		return queryAX(ctxt, h, n, func(ax *accessibility.AXNode) bool {
+			if axString(ax.Role) != role {
+				return false
+			}
+
+			return s.axName == nil || axString(ax.Name) == *s.axName
+		})
+	})(s)
+}
+
+// ByLabel is a query option to select a single form control (ie, a textbox,
+// checkbox or button) by its accessible name (ie, the text of its label
+// element, or its aria-label attribute), as computed by the browser's
+// accessibility tree. The label element itself is never selected.
+//
+// For example, the textbox labelled Email:
+//
+//	SendKeys("Email", "user@example.com", ByLabel)
+func ByLabel(s *Selector) {
+	ByFunc(func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) ([]cdp.NodeID, error) {
+		name := s.selAsString()
+		return queryAX(ctxt, h, n, func(ax *accessibility.AXNode) bool {
+			return labelableRoles[axString(ax.Role)] && axString(ax.Name) == name
+		})
+	})(s)
+}
+
+// AXName is a query option to restrict ByRole to elements with the specified
+// accessible name.
+func AXName(name string) QueryOption {
+	return func(s *Selector) {
+		s.axName = &name
+	}
+}
+
+// queryAX returns the first element below n, in accessibility tree order (ie,
+// document order, apart from aria-owns), whose accessibility node is not
+// ignored and matches match.
+func queryAX(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node, match func(*accessibility.AXNode) bool) ([]cdp.NodeID, error) {
+	id, err := walkAX(ctxt, h, n.NodeID, n.BackendNodeID, match)
+	if err != nil || id == emptyNodeID {
+		return []cdp.NodeID{}, err
+	}
+
+	return []cdp.NodeID{id}, nil
+}
+
+// walkAX searches the accessibility tree below the node with the specified
+// id and backend id, depth first, for the first accessibility node that is
+// not ignored and matches match, returning the id of its node.
+//
+// Each accessibility node's children are retrieved (with GetPartialAXTree)
+// only when its preceding nodes did not match, so that the walk stops as soon
+// as a match is found.
+func walkAX(ctxt context.Context, h cdp.FrameHandler, id cdp.NodeID, backendID cdp.BackendNodeID, match func(*accessibility.AXNode) bool) (cdp.NodeID, error) {
+	nodes, err := accessibility.GetPartialAXTree(id).WithFetchRelatives(true).Do(ctxt, h)
+	if err != nil {
+		if ctxt.Err() != nil {
+			return emptyNodeID, err
+		}
+
+		// nodes without an accessibility node are skipped
+		return emptyNodeID, nil
+	}
+
+	// find the node's accessibility node, and its children
+	var self *accessibility.AXNode
+	axNodes := make(map[accessibility.AXNodeID]*accessibility.AXNode)
+	for _, ax := range nodes {
+		axNodes[ax.NodeID] = ax
+		if self == nil && ax.BackendDOMNodeID == backendID {
+			self = ax
+		}
+	}
+	if self == nil {
+		return emptyNodeID, nil
+	}
+
+	var children []*accessibility.AXNode
+	var backendIDs []cdp.BackendNodeID
+	for _, childID := range self.ChildIds {
+		if c, ok := axNodes[childID]; ok && c.BackendDOMNodeID != 0 {
+			children = append(children, c)
+			backendIDs = append(backendIDs, c.BackendDOMNodeID)
+		}
+	}
+	if len(children) == 0 {
+		return emptyNodeID, nil
+	}
+
+	ids, err := dom.PushNodesByBackendIdsToFrontend(backendIDs).Do(ctxt, h)
+	if err != nil {
+		return emptyNodeID, err
+	}
+
+	for i, c := range children {
+		if i >= len(ids) || ids[i] == emptyNodeID {
+			continue
+		}
+
+		if !c.Ignored && match(c) {
+			return ids[i], nil
+		}
+
+		if len(c.ChildIds) != 0 {
+			found, err := walkAX(ctxt, h, ids[i], c.BackendDOMNodeID, match)
+			if err != nil || found != emptyNodeID {
+				return found, err
+			}
+		}
+	}
+
+	return emptyNodeID, nil
+}
+
+// labelableRoles are the accessibility roles of labelable elements (ie,
+// input, select, textarea, button, meter, output and progress elements), and
+// of the equivalent ARIA widgets.
+//
+// Besides the ARIA roles, Chromium reports its own internal role names for
+// some controls, which are also included: PopUpButton (select), ColorWell
+// (input type color), Date, DateTime and InputTime (date and time inputs),
+// and ToggleButton (buttons with aria-pressed).
+var labelableRoles = map[string]bool{
+	"button":           true,
+	"checkbox":         true,
+	"ColorWell":        true,
+	"combobox":         true,
+	"Date":             true,
+	"DateTime":         true,
+	"InputTime":        true,
+	"listbox":          true,
+	"menuitemcheckbox": true,
+	"menuitemradio":    true,
+	"meter":            true,
+	"PopUpButton":      true,
+	"progressbar":      true,
+	"radio":            true,
+	"searchbox":        true,
+	"slider":           true,
+	"spinbutton":       true,
+	"status":           true,
+	"switch":           true,
+	"textbox":          true,
+	"ToggleButton":     true,
+}
+
+// axString returns the string value of v, with leading and trailing
+// whitespace removed.
+func axString(v *accessibility.AXValue) string {
+	if v == nil || len(v.Value) == 0 {
+		return ""
+	}
+
+	var s string
+	if err := json.Unmarshal(v.Value, &s); err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,539 @@
+package chromedp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/mailru/easyjson"
+
+	"github.com/knq/chromedp/cdp"
+	"github.com/knq/chromedp/cdp/target"
+	"github.com/knq/chromedp/client"
+)
+
+var (
+	_ cdp.FrameHandler = &Browser{}
+)
+
+// ErrNoFrames is the error returned when a frame operation is attempted
+// against a browser level connection.
+var ErrNoFrames = errors.New("browser connection has no frames")
+
+// eventTargetInfoChanged is the target info changed event, sent by Chrome
+// versions newer than the generated protocol definitions.
+const eventTargetInfoChanged = "Target.targetInfoChanged"
+
+// Browser manages a browser level Chrome Debugging Protocol connection, used
+// for discovering and managing the targets of a Chrome instance.
+type Browser struct {
+	// target is the browser client target.
+	target client.Target
+
+	conn client.Transport
+
+	// qcmd is the outgoing message queue.
+	qcmd chan *cdp.Message
+
+	// last is the last sent message identifier.
+	last  int64
+	lastm sync.Mutex
+
+	// res is the id->result channel map.
+	res   map[int64]chan interface{}
+	resrw sync.RWMutex
+
+	// targets is the set of known targets.
+	targets map[target.ID]*target.Info
+
+	// watchers are the active target event watchers.
+	watchers []*targetWatcher
+
+	// discover indicates target discovery has been enabled.
+	discover bool
+
+	// sessions are the attached target sessions.
+	sessions map[target.ID]*session
+
+	// done is closed when the run loop has finished.
+	done chan struct{}
+
+	sync.RWMutex
+}
+
+// NewBrowser creates a new manager for the specified browser client target.
+func NewBrowser(t client.Target) (*Browser, error) {
+	conn, err := client.Dial(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Browser{
+		target: t,
+		conn:   conn,
+	}, nil
+}
+
+// Run starts the processing of commands and events to the browser target
+// provided to NewBrowser.
+//
+// Callers can stop Run by closing the passed context.
+func (b *Browser) Run(ctxt context.Context) error {
+	b.Lock()
+	b.qcmd = make(chan *cdp.Message)
+	b.res = make(map[int64]chan interface{})
+	b.targets = make(map[target.ID]*target.Info)
+	b.done = make(chan struct{})
+	b.Unlock()
+
+	go b.run(ctxt)
+
+	return nil
+}
+
+// run handles the actual message processing to / from the web socket
+// connection.
+func (b *Browser) run(ctxt context.Context) {
+	defer b.closeSessions()
+	defer close(b.done)
+	defer b.conn.Close()
+
+	// add cancel to context
+	ctxt, cancel := context.WithCancel(ctxt)
+	defer cancel()
+
+	qmsg := make(chan *browserMessage)
+	go func() {
+		defer cancel()
+
+		for {
+			msg, err := b.read()
+			if err != nil {
+				return
+			}
+
+			select {
+			case qmsg <- msg:
+			case <-ctxt.Done():
+				return
+			}
+		}
+	}()
+
+	var err error
+	for {
+		select {
+		case msg := <-qmsg:
+			switch {
+			case msg.Method != "":
+				err = b.processEvent(msg)
+				if err != nil {
+					log.Printf("could not process browser event, got: %v", err)
+				}
+
+			case msg.ID != 0:
+				b.processResult(msg)
+
+			default:
+				log.Printf("ignoring malformed incoming browser message (missing id or method): %#v", msg)
+			}
+
+		case cmd := <-b.qcmd:
+			err = b.processCommand(cmd)
+			if err != nil {
+				log.Printf("could not process browser command, got: %v", err)
+			}
+
+		case <-ctxt.Done():
+			return
+		}
+	}
+}
+
+// browserMessage is a message read from the browser connection.
+//
+// Unlike cdp.Message, the method is not validated against the generated
+// method types, as the browser may send events newer than the protocol
+// definitions.
+type browserMessage struct {
+	ID     int64             `json:"id,omitempty"`
+	Method string            `json:"method,omitempty"`
+	Params json.RawMessage   `json:"params,omitempty"`
+	Result json.RawMessage   `json:"result,omitempty"`
+	Error  *cdp.MessageError `json:"error,omitempty"`
+}
+
+// read reads a message from the browser connection.
+func (b *Browser) read() (*browserMessage, error) {
+	// read
+	buf, err := b.conn.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("-> %s", string(buf))
+
+	// unmarshal
+	msg := new(browserMessage)
+	err = json.Unmarshal(buf, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// processEvent processes an incoming browser event.
+func (b *Browser) processEvent(msg *browserMessage) error {
+	switch msg.Method {
+	case cdp.EventTargetTargetCreated.String(), eventTargetInfoChanged:
+		ev := new(target.EventTargetCreated)
+		err := easyjson.Unmarshal(msg.Params, ev)
+		if err != nil {
+			return err
+		}
+		if ev.TargetInfo != nil {
+			b.targetCreated(ev.TargetInfo)
+		}
+
+	case cdp.EventTargetTargetDestroyed.String():
+		ev := new(target.EventTargetDestroyed)
+		err := easyjson.Unmarshal(msg.Params, ev)
+		if err != nil {
+			return err
+		}
+		b.targetDestroyed(ev.TargetID)
+
+	case cdp.EventTargetReceivedMessageFromTarget.String():
+		ev := new(target.EventReceivedMessageFromTarget)
+		err := easyjson.Unmarshal(msg.Params, ev)
+		if err != nil {
+			return err
+		}
+		b.received(ev.TargetID, ev.Message)
+
+	case cdp.EventTargetDetachedFromTarget.String():
+		ev := new(target.EventDetachedFromTarget)
+		err := easyjson.Unmarshal(msg.Params, ev)
+		if err != nil {
+			return err
+		}
+		b.detached(ev.TargetID)
+	}
+
+	return nil
+}
+
+// closeSessions closes all attached target sessions.
+func (b *Browser) closeSessions() {
+	b.RLock()
+	ids := make([]target.ID, 0, len(b.sessions))
+	for id := range b.sessions {
+		ids = append(ids, id)
+	}
+	b.RUnlock()
+
+	for _, id := range ids {
+		b.detached(id)
+	}
+}
+
+// processResult processes an incoming command result.
+func (b *Browser) processResult(msg *browserMessage) {
+	b.resrw.Lock()
+	defer b.resrw.Unlock()
+
+	res, ok := b.res[msg.ID]
+	if !ok {
+		log.Printf("ignoring browser result for unknown message id %d", msg.ID)
+		return
+	}
+
+	if msg.Error != nil {
+		res <- msg.Error
+	} else {
+		res <- easyjson.RawMessage(msg.Result)
+	}
+
+	delete(b.res, msg.ID)
+}
+
+// processCommand writes a command to the browser connection.
+func (b *Browser) processCommand(cmd *cdp.Message) error {
+	buf, err := easyjson.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("<- %s", string(buf))
+
+	// write
+	return b.conn.Write(buf)
+}
+
+// Execute executes commandType against the browser target passed to
+// NewBrowser, using the provided context and the raw JSON encoded params.
+//
+// See TargetHandler.Execute for information on the returned channel.
+func (b *Browser) Execute(ctxt context.Context, commandType cdp.MethodType, params easyjson.RawMessage) <-chan interface{} {
+	ch := make(chan interface{}, 1)
+
+	go func() {
+		defer close(ch)
+
+		res := make(chan interface{}, 1)
+
+		// get next id
+		b.lastm.Lock()
+		b.last++
+		id := b.last
+		b.lastm.Unlock()
+
+		// save channel
+		b.resrw.Lock()
+		b.res[id] = res
+		b.resrw.Unlock()
+
+		// remove channel if the result is never received
+		defer func() {
+			b.resrw.Lock()
+			delete(b.res, id)
+			b.resrw.Unlock()
+		}()
+
+		select {
+		case b.qcmd <- &cdp.Message{
+			ID:     id,
+			Method: commandType,
+			Params: params,
+		}:
+
+		case <-b.done:
+			ch <- cdp.ErrChannelClosed
+			return
+
+		case <-ctxt.Done():
+			ch <- cdp.ErrContextDone
+			return
+		}
+
+		select {
+		case v := <-res:
+			if v != nil {
+				ch <- v
+			} else {
+				ch <- cdp.ErrChannelClosed
+			}
+
+		case <-b.done:
+			ch <- cdp.ErrChannelClosed
+
+		case <-ctxt.Done():
+			ch <- cdp.ErrContextDone
+		}
+	}()
+
+	return ch
+}
+
+// Listen satisfies the cdp.FrameHandler interface. Browser events are
+// retrieved through WatchTargets.
+func (b *Browser) Listen(ctxt context.Context, eventTypes ...cdp.MethodType) <-chan interface{} {
+	return nil
+}
+
+// SetActive satisfies the cdp.FrameHandler interface, always returning
+// ErrNoFrames.
+func (b *Browser) SetActive(context.Context, cdp.FrameID) error {
+	return ErrNoFrames
+}
+
+// GetRoot satisfies the cdp.FrameHandler interface, always returning
+// ErrNoFrames.
+func (b *Browser) GetRoot(context.Context) (*cdp.Node, error) {
+	return nil, ErrNoFrames
+}
+
+// WaitFrame satisfies the cdp.FrameHandler interface, always returning
+// ErrNoFrames.
+func (b *Browser) WaitFrame(context.Context, cdp.FrameID) (*cdp.Frame, error) {
+	return nil, ErrNoFrames
+}
+
+// WaitNode satisfies the cdp.FrameHandler interface, always returning
+// ErrNoFrames.
+func (b *Browser) WaitNode(context.Context, *cdp.Frame, cdp.NodeID) (*cdp.Node, error) {
+	return nil, ErrNoFrames
+}
+
+// TargetEventType is the type of a target event.
+type TargetEventType int
+
+// TargetEventType values.
+const (
+	TargetCreated TargetEventType = iota
+	TargetChanged
+	TargetDestroyed
+)
+
+// String satisfies stringer.
+func (t TargetEventType) String() string {
+	switch t {
+	case TargetCreated:
+		return "created"
+	case TargetChanged:
+		return "changed"
+	case TargetDestroyed:
+		return "destroyed"
+	}
+
+	return "unknown"
+}
+
+// TargetEvent is a target created, changed, or destroyed notification.
+type TargetEvent struct {
+	Type TargetEventType
+	ID   target.ID
+
+	// Info is the last known target info. For destroyed targets, Info is nil
+	// if the target was not previously known.
+	Info *target.Info
+}
+
+// targetWatcher is a registered target event watcher.
+type targetWatcher struct {
+	in   chan interface{}
+	done <-chan struct{}
+}
+
+// WatchTargets returns a channel that receives a created event for every
+// target known to the browser, followed by created, changed, and destroyed
+// events for targets of all types as they occur.
+//
+// The returned channel is closed when the passed context is done.
+func (b *Browser) WatchTargets(ctxt context.Context) (<-chan *TargetEvent, error) {
+	var err error
+
+	w := &targetWatcher{
+		in:   make(chan interface{}),
+		done: ctxt.Done(),
+	}
+	queue := make(chan interface{})
+	go forward(ctxt, w.in, queue)
+
+	out := make(chan *TargetEvent)
+	go func() {
+		defer close(out)
+
+		for ev := range queue {
+			select {
+			case out <- ev.(*TargetEvent):
+			case <-ctxt.Done():
+				return
+			}
+		}
+	}()
+
+	// register, sending the currently known targets
+	b.Lock()
+	for id, info := range b.targets {
+		w.send(&TargetEvent{Type: TargetCreated, ID: id, Info: info})
+	}
+	b.watchers = append(b.watchers, w)
+	discover := b.discover
+	b.discover = true
+	b.Unlock()
+
+	if discover {
+		return out, nil
+	}
+
+	// enable discovery
+	infos, err := target.GetTargets().Do(ctxt, b)
+	if err == nil {
+		for _, info := range infos {
+			b.targetCreated(info)
+		}
+
+		err = target.SetDiscoverTargets(true).Do(ctxt, b)
+	}
+	if err != nil {
+		b.Lock()
+		b.discover = false
+		b.Unlock()
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// send sends the event to the watcher, returning false if the watcher is
+// done.
+func (w *targetWatcher) send(ev *TargetEvent) bool {
+	select {
+	case w.in <- ev:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// broadcast sends the event to all watchers, removing any that are done. b
+// must be locked.
+func (b *Browser) broadcast(ev *TargetEvent) {
+	watchers := b.watchers[:0]
+	for _, w := range b.watchers {
+		if w.send(ev) {
+			watchers = append(watchers, w)
+		}
+	}
+	b.watchers = watchers
+}
+
+// targetCreated handles a created (or changed) target.
+func (b *Browser) targetCreated(info *target.Info) {
+	b.Lock()
+	defer b.Unlock()
+
+	typ := TargetCreated
+	if prev, ok := b.targets[info.TargetID]; ok {
+		if *prev == *info {
+			return
+		}
+		typ = TargetChanged
+	}
+	b.targets[info.TargetID] = info
+
+	b.broadcast(&TargetEvent{Type: typ, ID: info.TargetID, Info: info})
+}
+
+// targetDestroyed handles a destroyed target.
+func (b *Browser) targetDestroyed(id target.ID) {
+	b.Lock()
+	defer b.Unlock()
+
+	info := b.targets[id]
+	delete(b.targets, id)
+
+	b.broadcast(&TargetEvent{Type: TargetDestroyed, ID: id, Info: info})
+}
+
+// ClientTarget returns the client target for the specified target info,
+// usable with NewTargetHandler.
+func (b *Browser) ClientTarget(info *target.Info) client.Target {
+	urlstr := b.target.GetWebsocketURL()
+	if i := strings.LastIndex(urlstr, "/devtools/"); i != -1 {
+		urlstr = urlstr[:i]
+	}
+
+	return &client.Chrome{
+		ID:           info.TargetID.String(),
+		Title:        info.Title,
+		Type:         client.TargetType(info.Type),
+		URL:          info.URL,
+		WebsocketURL: urlstr + "/devtools/page/" + info.TargetID.String(),
+	}
+}
@@ -0,0 +1,158 @@
+package chromedp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/knq/chromedp/cdp"
+	"github.com/knq/chromedp/cdp/target"
+)
+
+// ErrNoBrowser is the error returned when an action requires a browser
+// level connection, but none is available.
+var ErrNoBrowser = errors.New("no browser connection available")
+
+// getBrowser returns the browser level connection.
+func (c *CDP) getBrowser() (*Browser, error) {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.b == nil {
+		return nil, ErrNoBrowser
+	}
+
+	return c.b, nil
+}
+
+// NewBrowserContext is an action that creates a new browser context (ie, an
+// incognito session) isolating cookies, storage, and cache from the other
+// targets of the Chrome instance, storing the created context's id in
+// contextID.
+//
+// Targets can be opened in the context with NewContextTarget, and the
+// context and its targets closed with DisposeBrowserContext.
+func (c *CDP) NewBrowserContext(contextID *target.BrowserContextID) Action {
+	if contextID == nil {
+		panic("contextID cannot be nil")
+	}
+
+	return ActionFunc(func(ctxt context.Context, h cdp.FrameHandler) error {
+		b, err := c.getBrowser()
+		if err != nil {
+			return err
+		}
+
+		id, err := target.CreateBrowserContext().Do(ctxt, b)
+		if err != nil {
+			return err
+		}
+
+		c.Lock()
+		c.contexts[id] = nil
+		c.Unlock()
+
+		*contextID = id
+
+		return nil
+	})
+}
+
+// NewContextTarget is an action that creates a new Chrome target in the
+// browser context contextID, and sets it as the active target. If urlstr is
+// not empty, then the target is navigated to urlstr, and the action returns
+// only after the target's frame has fired its load event.
+//
+// The id of the created target is stored in id, if not nil.
+func (c *CDP) NewContextTarget(contextID target.BrowserContextID, urlstr string, id *string) Action {
+	return ActionFunc(func(ctxt context.Context, h cdp.FrameHandler) error {
+		b, err := c.getBrowser()
+		if err != nil {
+			return err
+		}
+
+		c.RLock()
+		_, ok := c.contexts[contextID]
+		c.RUnlock()
+		if !ok {
+			return fmt.Errorf("unknown browser context %s", contextID)
+		}
+
+		tid, err := target.CreateTarget("about:blank").
+			WithBrowserContextID(contextID).
+			Do(ctxt, b)
+		if err != nil {
+			return err
+		}
+		n := tid.String()
+
+		c.Lock()
+		c.contexts[contextID] = append(c.contexts[contextID], n)
+		c.Unlock()
+
+		err = c.waitTarget(ctxt, n)
+		if err != nil {
+			return err
+		}
+
+		if urlstr != "" {
+			err = c.navigateTarget(ctxt, n, urlstr)
+			if err != nil {
+				return err
+			}
+		}
+
+		if id != nil {
+			*id = n
+		}
+
+		return nil
+	})
+}
+
+// DisposeBrowserContext is an action that disposes the browser context
+// contextID, closing all of its targets and removing their handlers.
+func (c *CDP) DisposeBrowserContext(contextID target.BrowserContextID) Action {
+	return ActionFunc(func(ctxt context.Context, h cdp.FrameHandler) error {
+		b, err := c.getBrowser()
+		if err != nil {
+			return err
+		}
+
+		c.RLock()
+		ids, ok := c.contexts[contextID]
+		c.RUnlock()
+		if !ok {
+			return fmt.Errorf("unknown browser context %s", contextID)
+		}
+
+		// close targets
+		for _, id := range ids {
+			c.RLock()
+			_, ok := c.handlerMap[id]
+			c.RUnlock()
+			if !ok {
+				continue
+			}
+
+			err = c.closeHandler(ctxt, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		ok, err = target.DisposeBrowserContext(contextID).Do(ctxt, b)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("could not dispose browser context %s", contextID)
+		}
+
+		c.Lock()
+		delete(c.contexts, contextID)
+		c.Unlock()
+
+		return nil
+	})
+}
@@ -10,21 +10,26 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/knq/chromedp/cdp"
+	"github.com/knq/chromedp/cdp/target"
 	"github.com/knq/chromedp/client"
 	"github.com/knq/chromedp/runner"
 )
 
 const (
-	// DefaultNewTargetTimeout is the default time to wait for a new target to
-	// be started.
+	// DefaultNewTargetTimeout is the default time to wait for the initial and
+	// newly created targets to become available (see WithNewTargetTimeout).
 	DefaultNewTargetTimeout = 3 * time.Second
 
 	// DefaultCheckDuration is the default time to sleep between a check.
 	DefaultCheckDuration = 50 * time.Millisecond
+
+	// DefaultKeyDelay is the default time to wait between typed keys.
+	DefaultKeyDelay = 100 * time.Millisecond
 )
 
 // CDP contains information for managing a Chrome process runner, low level
@@ -39,15 +44,44 @@ type CDP struct {
 	// watch is the channel for new client targets.
 	watch <-chan client.Target
 
+	// b is the browser level connection used for target discovery.
+	b *Browser
+
+	// browser is the browser level target to connect to, when not using a
+	// runner.
+	browser client.Target
+
+	// sessions toggles multiplexing target connections over the browser
+	// level connection.
+	sessions bool
+
+	// contexts is the map of created browser contexts to the ids of the
+	// targets opened in them.
+	contexts map[target.BrowserContextID][]string
+
 	// cur is the current active target's handler.
 	cur cdp.FrameHandler
 
 	// handlers is the active handlers.
-	handlers []cdp.FrameHandler
+	handlers []*TargetHandler
 
 	// handlerMap is the map of target IDs to its active handler.
 	handlerMap map[string]int
 
+	// added is closed, and replaced, each time a handler is added.
+	added chan struct{}
+
+	// newTargetTimeout is the time to wait for the initial and newly created
+	// targets to become available.
+	newTargetTimeout time.Duration
+
+	// queryTimeout is the default timeout for queries run against the
+	// handlers.
+	queryTimeout time.Duration
+
+	// pollInterval is the default interval queries re-check their conditions.
+	pollInterval time.Duration
+
 	sync.RWMutex
 }
 
@@ -56,8 +90,11 @@ func New(ctxt context.Context, opts ...Option) (*CDP, error) {
 	var err error
 
 	c := &CDP{
-		handlers:   make([]cdp.FrameHandler, 0),
-		handlerMap: make(map[string]int),
+		handlers:         make([]*TargetHandler, 0),
+		handlerMap:       make(map[string]int),
+		added:            make(chan struct{}),
+		contexts:         make(map[target.BrowserContextID][]string),
+		newTargetTimeout: DefaultNewTargetTimeout,
 	}
 
 	// apply options
@@ -68,6 +105,12 @@ func New(ctxt context.Context, opts ...Option) (*CDP, error) {
 		}
 	}
 
+	// sessions are attached through the browser connection, which is only
+	// available when discovering targets through it
+	if c.sessions && c.watch != nil {
+		return nil, errors.New("sessions cannot be used with WithTargets")
+	}
+
 	// setup context
 	if ctxt == nil {
 		var cancel func()
@@ -76,59 +119,155 @@ func New(ctxt context.Context, opts ...Option) (*CDP, error) {
 	}
 
 	// check for supplied runner, if none then create one
-	if c.r == nil && c.watch == nil {
+	if c.r == nil && c.watch == nil && c.browser == nil {
 		c.r, err = runner.Run(ctxt, c.opts...)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	// watch handlers
+	// watch handlers, preferring target discovery through the browser
+	// connection over polling for new targets
 	if c.watch == nil {
-		c.watch = c.r.WatchPageTargets(ctxt)
+		err = c.watchBrowser(ctxt)
+		if err != nil && (c.sessions || c.r == nil) {
+			return nil, err
+		}
+		if err != nil {
+			log.Printf("could not watch browser targets, falling back to polling, got: %v", err)
+			c.watch = c.r.WatchPageTargets(ctxt)
+		}
+	}
+
+	if c.watch != nil {
+		go func() {
+			for t := range c.watch {
+				go c.AddTarget(ctxt, t)
+			}
+		}()
 	}
 
+	// wait until at least one target active
+	err = c.waitHandlers(ctxt, "initial target", func() bool {
+		return c.cur != nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// watchBrowser connects to the browser level target of the runner, adding
+// and removing page target handlers as the browser reports page targets
+// being created and destroyed.
+func (c *CDP) watchBrowser(ctxt context.Context) error {
+	var err error
+
+	// retrieve browser target, retrying while the runner is starting
+	timeout := time.After(client.DefaultWatchTimeout)
+	t := c.browser
+	for t == nil {
+		t, err = c.r.Client().BrowserTarget(ctxt)
+		if err == nil {
+			break
+		}
+		if err == client.ErrBrowserTargetUnavailable {
+			return err
+		}
+
+		select {
+		case <-time.After(client.DefaultWatchInterval):
+
+		case <-ctxt.Done():
+			return cdp.ErrContextDone
+
+		case <-timeout:
+			return err
+		}
+	}
+
+	b, err := NewBrowser(t)
+	if err != nil {
+		return err
+	}
+
+	err = b.Run(ctxt)
+	if err != nil {
+		return err
+	}
+
+	events, err := b.WatchTargets(ctxt)
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	c.b = b
+	c.Unlock()
+
 	go func() {
-		for t := range c.watch {
-			go c.AddTarget(ctxt, t)
+		for ev := range events {
+			switch ev.Type {
+			case TargetCreated:
+				if ev.Info.Type == client.Page.String() {
+					go c.AddTarget(ctxt, b.ClientTarget(ev.Info))
+				}
+
+			case TargetDestroyed:
+				var h *TargetHandler
+				c.Lock()
+				if i, ok := c.handlerMap[ev.ID.String()]; ok {
+					h = c.removeHandler(i)
+				}
+				c.Unlock()
+
+				if h != nil {
+					stopHandler(h)
+				}
+			}
 		}
 	}()
 
-	// TODO: fix this
-	timeout := time.After(DefaultNewTargetTimeout)
+	return nil
+}
+
+// waitHandlers waits until check returns true, re-checking each time a
+// handler is added. check is called with c read locked.
+func (c *CDP) waitHandlers(ctxt context.Context, what string, check func() bool) error {
+	if c.newTargetTimeout > 0 {
+		var cancel context.CancelFunc
+		ctxt, cancel = context.WithTimeout(ctxt, c.newTargetTimeout)
+		defer cancel()
+	}
 
-loop:
-	// wait until at least one target active
 	for {
-		select {
-		default:
-			c.RLock()
-			exists := c.cur != nil
-			c.RUnlock()
-			if exists {
-				return c, nil
-			}
+		c.RLock()
+		ok, added := check(), c.added
+		c.RUnlock()
+		if ok {
+			return nil
+		}
 
-			time.Sleep(DefaultCheckDuration)
+		select {
+		case <-added:
 
 		case <-ctxt.Done():
-			return nil, cdp.ErrContextDone
-
-		case <-timeout:
-			break loop
+			if ctxt.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("timeout waiting for %s", what)
+			}
+			return fmt.Errorf("context done waiting for %s", what)
 		}
 	}
-
-	return nil, errors.New("timeout waiting for initial target")
 }
 
 // AddTarget adds a target using the supplied context.
+//
+// The handler is created and started without holding c's lock, so that
+// starting a target does not block the other targets.
 func (c *CDP) AddTarget(ctxt context.Context, t client.Target) {
-	c.Lock()
-	defer c.Unlock()
-
 	// create target manager
-	h, err := NewTargetHandler(t)
+	h, err := c.newHandler(ctxt, t)
 	if err != nil {
 		log.Printf("error: could not create handler for %s, got: %v", t, err)
 		return
@@ -138,6 +277,16 @@ func (c *CDP) AddTarget(ctxt context.Context, t client.Target) {
 	err = h.Run(ctxt)
 	if err != nil {
 		log.Printf("error: could not start handler for %s, got: %v", t, err)
+		stopHandler(h)
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.handlerMap[t.GetID()]; ok {
+		log.Printf("error: target %s already has a handler", t)
+		go stopHandler(h)
 		return
 	}
 
@@ -147,6 +296,71 @@ func (c *CDP) AddTarget(ctxt context.Context, t client.Target) {
 	if c.cur == nil {
 		c.cur = h
 	}
+
+	// notify waiters
+	close(c.added)
+	c.added = make(chan struct{})
+
+	go c.watchHandler(h)
+}
+
+// watchHandler removes the handler once it has terminated (ie, when the
+// target crashed or was detached).
+func (c *CDP) watchHandler(h *TargetHandler) {
+	h.RLock()
+	term := h.term
+	h.RUnlock()
+
+	<-term
+
+	c.Lock()
+	i, ok := c.handlerMap[h.GetTarget().GetID()]
+	ok = ok && c.handlers[i] == h
+	if ok {
+		c.removeHandler(i)
+	}
+	c.Unlock()
+
+	if ok {
+		stopHandler(h)
+	}
+}
+
+// stopHandler stops the handler's run loop and closes its connection.
+func stopHandler(h *TargetHandler) {
+	err := h.Close()
+	if err != nil {
+		log.Printf("error: could not close handler for %s, got: %v", h.GetTarget(), err)
+	}
+}
+
+// newHandler creates a handler for the target, either connecting to the
+// target directly, or, when using sessions, attaching to the target through
+// the browser connection.
+func (c *CDP) newHandler(ctxt context.Context, t client.Target) (*TargetHandler, error) {
+	c.RLock()
+	sessions, b := c.sessions, c.b
+	queryTimeout, pollInterval := c.queryTimeout, c.pollInterval
+	c.RUnlock()
+
+	var h *TargetHandler
+	if sessions {
+		conn, err := b.Attach(ctxt, target.ID(t.GetID()))
+		if err != nil {
+			return nil, err
+		}
+		h = NewTargetHandlerWithTransport(t, conn)
+	} else {
+		var err error
+		h, err = NewTargetHandler(t)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	h.queryTimeout, h.pollInterval = queryTimeout, pollInterval
+
+	return h, nil
 }
 
 // Wait waits for the Chrome runner to terminate.
@@ -165,21 +379,35 @@ func (c *CDP) Wait() error {
 // Shutdown closes all Chrome page handlers.
 func (c *CDP) Shutdown(ctxt context.Context, opts ...client.Option) error {
 	c.RLock()
-	defer c.RUnlock()
+	r := c.r
+	ids := make([]string, 0, len(c.handlers))
+	for _, h := range c.handlers {
+		ids = append(ids, h.GetTarget().GetID())
+	}
+	c.RUnlock()
+
+	if r != nil {
+		return r.Shutdown(ctxt, opts...)
+	}
+
+	for _, id := range ids {
+		err := c.closeHandler(ctxt, id, opts...)
+		if err != nil {
+			return err
+		}
+	}
 
-	return c.r.Shutdown(ctxt, opts...)
+	return nil
 }
 
-// ListTargets returns the target IDs of the managed targets.
+// ListTargets returns the target IDs of the managed targets, in index order.
 func (c *CDP) ListTargets() []string {
 	c.RLock()
 	defer c.RUnlock()
 
 	targets := make([]string, len(c.handlers))
-	i := 0
-	for k := range c.handlerMap {
-		targets[i] = k
-		i++
+	for i, h := range c.handlers {
+		targets[i] = h.GetTarget().GetID()
 	}
 
 	return targets
@@ -230,51 +458,175 @@ func (c *CDP) SetHandlerByID(id string) error {
 
 	if i, ok := c.handlerMap[id]; ok {
 		c.cur = c.handlers[i]
+		return nil
 	}
 
 	return fmt.Errorf("no handler associated with target id %s", id)
 }
 
-// newTarget creates a new target using supplied context and options, returning
-// the id of the created target only after the target has been started for
-// monitoring.
-func (c *CDP) newTarget(ctxt context.Context, opts ...client.Option) (string, error) {
+// client returns a client for the Chrome runner, or, if no runner is
+// available, a new client created with the supplied options.
+func (c *CDP) client(opts ...client.Option) *client.Client {
 	c.RLock()
-	cl := c.r.Client(opts...)
+	defer c.RUnlock()
+
+	if c.r != nil {
+		return c.r.Client(opts...)
+	}
+
+	return client.New(opts...)
+}
+
+// closeHandler closes the target for the handler with the specified target
+// id, stopping the handler and removing it from the active handlers.
+func (c *CDP) closeHandler(ctxt context.Context, id string, opts ...client.Option) error {
+	cl := c.client(opts...)
+
+	c.RLock()
+	i, ok := c.handlerMap[id]
+	var h *TargetHandler
+	if ok {
+		h = c.handlers[i]
+	}
+	sessions, b := c.sessions, c.b
 	c.RUnlock()
+	if !ok {
+		return fmt.Errorf("no handler associated with target id %s", id)
+	}
 
-	// new page target
-	t, err := cl.NewPageTarget(ctxt)
+	// close target
+	var err error
+	if sessions {
+		_, err = target.CloseTarget(target.ID(id)).Do(ctxt, b)
+	} else {
+		err = cl.CloseTarget(ctxt, h.GetTarget())
+	}
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	timeout := time.After(DefaultNewTargetTimeout)
+	// remove the handler, unless it was removed (ie, by the target destroyed
+	// event) while closing the target
+	c.Lock()
+	i, ok = c.handlerMap[id]
+	ok = ok && c.handlers[i] == h
+	if ok {
+		c.removeHandler(i)
+	}
+	c.Unlock()
 
-loop:
-	for {
-		select {
-		default:
-			var ok bool
-			id := t.GetID()
-			c.RLock()
-			_, ok = c.handlerMap[id]
-			c.RUnlock()
-			if ok {
-				return id, nil
-			}
+	if ok {
+		stopHandler(h)
+	}
 
-			time.Sleep(DefaultCheckDuration)
+	return nil
+}
 
-		case <-ctxt.Done():
-			return "", cdp.ErrContextDone
+// removeHandler removes the handler with the specified index from the active
+// handlers, returning the handler so that it can be stopped (see stopHandler)
+// once c is unlocked. c must be locked.
+//
+// If the removed handler was the active handler, then the handler that took
+// its index (or the last handler, when there is none) is made the active
+// handler.
+func (c *CDP) removeHandler(i int) *TargetHandler {
+	h := c.handlers[i]
+	id := h.GetTarget().GetID()
+
+	// remove from active handlers, shifting the index of subsequent handlers
+	c.handlers = append(c.handlers[:i], c.handlers[i+1:]...)
+	delete(c.handlerMap, id)
+	for k, j := range c.handlerMap {
+		if j > i {
+			c.handlerMap[k] = j - 1
+		}
+	}
 
-		case <-timeout:
-			break loop
+	// pick new current handler
+	if c.cur == h {
+		switch {
+		case len(c.handlers) == 0:
+			c.cur = nil
+		case i < len(c.handlers):
+			c.cur = c.handlers[i]
+		default:
+			c.cur = c.handlers[len(c.handlers)-1]
+		}
+	}
+
+	return h
+}
+
+// newTarget creates a new target using supplied context and options, returning
+// the id of the created target only after the target has been started for
+// monitoring and has been set as the active target.
+func (c *CDP) newTarget(ctxt context.Context, opts ...client.Option) (string, error) {
+	var id string
+	if c.sessions {
+		// new page target through the browser connection
+		c.RLock()
+		b := c.b
+		c.RUnlock()
+
+		tid, err := target.CreateTarget("about:blank").Do(ctxt, b)
+		if err != nil {
+			return "", err
+		}
+		id = tid.String()
+	} else {
+		// new page target
+		t, err := c.client(opts...).NewPageTarget(ctxt)
+		if err != nil {
+			return "", err
 		}
+		id = t.GetID()
+	}
+
+	return id, c.waitTarget(ctxt, id)
+}
+
+// waitTarget waits for the target with the specified id to be started for
+// monitoring, and then sets it as the active target.
+func (c *CDP) waitTarget(ctxt context.Context, id string) error {
+	err := c.waitHandlers(ctxt, "new target to be available", func() bool {
+		_, ok := c.handlerMap[id]
+		return ok
+	})
+	if err != nil {
+		return err
 	}
 
-	return "", errors.New("timeout waiting for new target to be available")
+	return c.SetHandlerByID(id)
+}
+
+// navigateTarget navigates the target with the specified id to urlstr,
+// waiting for the target's frame to navigate and fire its load event.
+func (c *CDP) navigateTarget(ctxt context.Context, id, urlstr string) error {
+	var h *TargetHandler
+	c.RLock()
+	if i, ok := c.handlerMap[id]; ok {
+		h = c.handlers[i]
+	}
+	c.RUnlock()
+	if h == nil {
+		return errors.New("could not retrieve newly created target")
+	}
+
+	// get the loader of the blank page
+	f, err := h.WaitFrame(ctxt, emptyFrameID)
+	if err != nil {
+		return err
+	}
+	f.RLock()
+	loaderID := f.LoaderID
+	f.RUnlock()
+
+	err = Navigate(urlstr).Do(ctxt, h)
+	if err != nil {
+		return err
+	}
+
+	return h.waitLoaded(ctxt, loaderID)
 }
 
 // SetTarget is an action that sets the active Chrome handler to the specified
@@ -311,7 +663,8 @@ func (c *CDP) NewTarget(id *string, opts ...client.Option) Action {
 }
 
 // NewTargetWithURL creates a new Chrome target, sets it as the active target,
-// and then navigates to the specified url.
+// and then navigates to the specified url, returning only after the new
+// target's frame has navigated and fired its load event.
 func (c *CDP) NewTargetWithURL(urlstr string, id *string, opts ...client.Option) Action {
 	return ActionFunc(func(ctxt context.Context, h cdp.FrameHandler) error {
 		n, err := c.newTarget(ctxt, opts...)
@@ -319,40 +672,46 @@ func (c *CDP) NewTargetWithURL(urlstr string, id *string, opts ...client.Option)
 			return err
 		}
 
-		l := c.GetHandlerByID(n)
-		if l == nil {
-			return errors.New("could not retrieve newly created target")
-		}
-
-		/*err = Navigate(l, urlstr).Do(ctxt)
+		err = c.navigateTarget(ctxt, n, urlstr)
 		if err != nil {
 			return err
 		}
 
 		if id != nil {
 			*id = n
-		}*/
+		}
 
 		return nil
 	})
 }
 
 // CloseByIndex closes the Chrome target with specified index i.
-func (c *CDP) CloseByIndex(i int) Action {
+func (c *CDP) CloseByIndex(i int, opts ...client.Option) Action {
 	return ActionFunc(func(ctxt context.Context, h cdp.FrameHandler) error {
-		return nil
+		c.RLock()
+		if i < 0 || i >= len(c.handlers) {
+			c.RUnlock()
+			return fmt.Errorf("no handler associated with target index %d", i)
+		}
+		id := c.handlers[i].GetTarget().GetID()
+		c.RUnlock()
+
+		return c.closeHandler(ctxt, id, opts...)
 	})
 }
 
 // CloseByID closes the Chrome target with the specified id.
-func (c *CDP) CloseByID(id string) Action {
+func (c *CDP) CloseByID(id string, opts ...client.Option) Action {
 	return ActionFunc(func(ctxt context.Context, h cdp.FrameHandler) error {
-		return nil
+		return c.closeHandler(ctxt, id, opts...)
 	})
 }
 
 // Run executes the action against the current target using the supplied
 // context.
+//
+// Use Target to run actions against a specific target, independently of the
+// current target.
 func (c *CDP) Run(ctxt context.Context, a Action) error {
 	c.RLock()
 	cur := c.cur
@@ -374,7 +733,7 @@ func WithRunner(r *runner.Runner) Option {
 }
 
 // WithTargets is an option to specify the incoming targets to monitor for page
-// handlers.
+// handlers. Cannot be used with WithSessions or WithBrowserURL.
 func WithTargets(watch <-chan client.Target) Option {
 	return func(c *CDP) error {
 		c.watch = watch
@@ -390,3 +749,58 @@ func WithRunnerOptions(opts ...runner.CommandLineOption) Option {
 		return nil
 	}
 }
+
+// WithNewTargetTimeout is an option to specify the time to wait for the
+// initial and newly created targets to become available. Defaults to
+// DefaultNewTargetTimeout. When d is 0, only the passed context bounds the
+// wait.
+func WithNewTargetTimeout(d time.Duration) Option {
+	return func(c *CDP) error {
+		c.newTargetTimeout = d
+		return nil
+	}
+}
+
+// WithQueryTimeout is an option to specify the default timeout for queries
+// run against the page targets. A query's own timeout, when set with
+// QueryTimeout, takes precedence.
+func WithQueryTimeout(d time.Duration) Option {
+	return func(c *CDP) error {
+		c.queryTimeout = d
+		return nil
+	}
+}
+
+// WithPollInterval is an option to specify the default interval queries
+// re-check conditions that are not reported by DOM events (ie, visibility).
+// Defaults to DefaultCheckDuration.
+func WithPollInterval(d time.Duration) Option {
+	return func(c *CDP) error {
+		c.pollInterval = d
+		return nil
+	}
+}
+
+// WithSessions is an option to multiplex the connections to all page targets
+// over a single browser level connection, attaching to targets through the
+// Target domain instead of connecting to each target's websocket URL.
+func WithSessions(c *CDP) error {
+	c.sessions = true
+	return nil
+}
+
+// WithBrowserURL is an option to connect to the browser level websocket URL
+// of an already running Chrome instance, driving its page targets through
+// sessions multiplexed over the browser connection. Useful for instances that
+// only expose the browser endpoint.
+func WithBrowserURL(urlstr string) Option {
+	return func(c *CDP) error {
+		c.browser = &client.Chrome{
+			ID:           urlstr[strings.LastIndexByte(urlstr, '/')+1:],
+			Type:         client.Browser,
+			WebsocketURL: urlstr,
+		}
+		c.sessions = true
+		return nil
+	}
+}
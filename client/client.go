@@ -31,6 +31,10 @@ var (
 
 	// ErrUnsupportedProtocolVersion is the unsupported protocol version error.
 	ErrUnsupportedProtocolVersion = errors.New("unsupported protocol version")
+
+	// ErrBrowserTargetUnavailable is the error returned when the remote
+	// instance does not advertise a browser level websocket endpoint.
+	ErrBrowserTargetUnavailable = errors.New("browser target unavailable")
 )
 
 // Client is a Chrome Debugging Protocol client.
@@ -224,7 +228,7 @@ func (c *Client) ActivateTarget(ctxt context.Context, t Target) error {
 	return c.doReq(ctxt, "activate/"+t.GetID(), nil)
 }
 
-// CloseTarget activates a target.
+// CloseTarget closes a target.
 func (c *Client) CloseTarget(ctxt context.Context, t Target) error {
 	return c.doReq(ctxt, "close/"+t.GetID(), nil)
 }
@@ -242,6 +246,27 @@ func (c *Client) VersionInfo(ctxt context.Context) (map[string]string, error) {
 	return v, nil
 }
 
+// BrowserTarget returns the browser level target of the remote instance, as
+// advertised by its version information.
+func (c *Client) BrowserTarget(ctxt context.Context) (Target, error) {
+	v, err := c.VersionInfo(ctxt)
+	if err != nil {
+		return nil, err
+	}
+
+	urlstr := v["webSocketDebuggerUrl"]
+	if urlstr == "" {
+		return nil, ErrBrowserTargetUnavailable
+	}
+
+	return &Chrome{
+		ID:           urlstr[strings.LastIndexByte(urlstr, '/')+1:],
+		Title:        v["Browser"],
+		Type:         Browser,
+		WebsocketURL: urlstr,
+	}, nil
+}
+
 // WatchPageTargets watches for new page targets.
 func (c *Client) WatchPageTargets(ctxt context.Context) <-chan Target {
 	if ctxt == nil {
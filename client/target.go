@@ -22,6 +22,7 @@ type TargetType string
 // TargetType values.
 const (
 	BackgroundPage TargetType = "background_page"
+	Browser        TargetType = "browser"
 	Other          TargetType = "other"
 	Page           TargetType = "page"
 	ServiceWorker  TargetType = "service_worker"
@@ -47,6 +48,8 @@ func (tt *TargetType) UnmarshalEasyJSON(in *jlexer.Lexer) {
 	switch TargetType(in.String()) {
 	case BackgroundPage:
 		*tt = BackgroundPage
+	case Browser:
+		*tt = Browser
 	case Other:
 		*tt = Other
 	case Page:
@@ -423,7 +423,7 @@ type FrameHandler interface {
 	GetRoot(context.Context) (*Node, error)
 	WaitFrame(context.Context, FrameID) (*Frame, error)
 	WaitNode(context.Context, *Frame, NodeID) (*Node, error)
-	Listen(...MethodType) <-chan interface{}
+	Listen(context.Context, ...MethodType) <-chan interface{}
 
 	// Execute executes the specified command using the supplied context and
 	// parameters.
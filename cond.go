@@ -0,0 +1,374 @@
+package chromedp
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/knq/chromedp/cdp"
+)
+
+// ErrConditionNotMet is the error returned when a condition is not met.
+var ErrConditionNotMet = errors.New("condition not met")
+
+// Condition is a condition that a node must satisfy, returning an error when
+// the node does not satisfy the condition. Conditions are used as wait
+// conditions with WaitCondition.
+type Condition func(context.Context, cdp.FrameHandler, *cdp.Node) error
+
+// Visible is a condition that the node is visible.
+func Visible(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
+	hidden, err := isHidden(ctxt, h, n)
+	if err != nil {
+		return err
+	}
+	if hidden {
+		return ErrNotVisible
+	}
+
+	return nil
+}
+
+// NotVisible is a condition that the node is not visible.
+func NotVisible(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
+	hidden, err := isHidden(ctxt, h, n)
+	if err != nil {
+		return err
+	}
+	if !hidden {
+		return ErrVisible
+	}
+
+	return nil
+}
+
+// isHidden determines if the node is hidden, following WebDriver's element
+// displayed algorithm (see displayedJS). A node covered by another element is
+// not hidden, as overlays may not receive pointer events (see NotOccluded).
+//
+// All style and box checks are made in a single call, so the cost does not
+// depend on the depth of the node.
+func isHidden(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) (bool, error) {
+	var displayed bool
+	err := callValue(ctxt, h, n, displayedJS, &displayed)
+	if err != nil {
+		return false, err
+	}
+
+	return !displayed, nil
+}
+
+// NotOccluded is a condition that the center of the node's area within the
+// viewport is not covered by another element (ie, an overlay or a sticky
+// header), as determined by hit testing the location. Nodes outside the
+// viewport do not satisfy the condition, while nodes in child frames are not
+// checked.
+//
+// As hit testing does not skip elements with pointer-events none, the
+// condition is not part of Visible, and is instead combined with it as
+// needed. For example:
+//
+//	WaitCondition(`#submit`, And(Visible, NotOccluded))
+func NotOccluded(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
+	var pt *struct {
+		X, Y  float64
+		Frame bool
+	}
+	err := callValue(ctxt, h, n, viewportCenterJS, &pt)
+	if err != nil {
+		return err
+	}
+	switch {
+	case pt == nil:
+		return ErrNotVisible
+	case pt.Frame:
+		return nil
+	}
+
+	_, contains, err := hitTest(ctxt, h, n, int64(pt.X), int64(pt.Y))
+	if err != nil {
+		return err
+	}
+	if !contains {
+		return ErrOccluded
+	}
+
+	return nil
+}
+
+// Enabled is a condition that the node does not have the disabled attribute.
+func Enabled(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
+	if hasAttribute(n, "disabled") {
+		return ErrDisabled
+	}
+
+	return nil
+}
+
+// Selected is a condition that the node has the selected attribute.
+func Selected(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
+	if !hasAttribute(n, "selected") {
+		return ErrNotSelected
+	}
+
+	return nil
+}
+
+// hasAttribute determines if the node has the named attribute.
+func hasAttribute(n *cdp.Node, name string) bool {
+	n.RLock()
+	defer n.RUnlock()
+
+	for i := 0; i < len(n.Attributes); i += 2 {
+		if n.Attributes[i] == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasAttribute is a condition that the node has the named attribute.
+func HasAttribute(name string) Condition {
+	return func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
+		if !hasAttribute(n, name) {
+			return ErrConditionNotMet
+		}
+
+		return nil
+	}
+}
+
+// AttributeEquals is a condition that the node's named attribute is equal to
+// value.
+func AttributeEquals(name, value string) Condition {
+	return func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
+		if !hasAttribute(n, name) || n.AttributeValue(name) != value {
+			return ErrConditionNotMet
+		}
+
+		return nil
+	}
+}
+
+// TextEquals is a condition that the node's visible text (with leading and
+// trailing whitespace removed) is equal to text.
+func TextEquals(text string) Condition {
+	return func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
+		var s string
+		err := callValue(ctxt, h, n, nodeTextJS, &s)
+		if err != nil {
+			return err
+		}
+		if s != text {
+			return ErrConditionNotMet
+		}
+
+		return nil
+	}
+}
+
+// TextMatches is a condition that the node's visible text (with leading and
+// trailing whitespace removed) matches re.
+func TextMatches(re *regexp.Regexp) Condition {
+	return func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
+		var s string
+		err := callValue(ctxt, h, n, nodeTextJS, &s)
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(s) {
+			return ErrConditionNotMet
+		}
+
+		return nil
+	}
+}
+
+// Stable is a condition that there are no DOM mutations (attributes, child
+// nodes or character data) in the node's subtree for the duration d.
+func Stable(d time.Duration) Condition {
+	return func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
+		var stable bool
+		err := callValue(ctxt, h, n, stableJS, &stable, int64(d/time.Millisecond))
+		if err != nil {
+			return err
+		}
+		if !stable {
+			return ErrConditionNotMet
+		}
+
+		return nil
+	}
+}
+
+// And is a condition that the node satisfies all of conds.
+func And(conds ...Condition) Condition {
+	return func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
+		for _, c := range conds {
+			if err := c(ctxt, h, n); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// Or is a condition that the node satisfies at least one of conds.
+func Or(conds ...Condition) Condition {
+	return func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
+		err := ErrConditionNotMet
+		for _, c := range conds {
+			if err = c(ctxt, h, n); err == nil {
+				return nil
+			}
+		}
+
+		return err
+	}
+}
+
+// Not is a condition that the node does not satisfy cond. Errors other than
+// cond not being met (ie, a cancelled context) are passed through.
+func Not(cond Condition) Condition {
+	return func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
+		switch err := cond(ctxt, h, n); {
+		case err == nil:
+			return ErrConditionNotMet
+		case !notMet(err):
+			return err
+		}
+
+		return nil
+	}
+}
+
+// notMet determines if err is the error of a condition not being met, rather
+// than an error evaluating the condition.
+func notMet(err error) bool {
+	switch err {
+	case ErrConditionNotMet, ErrNotVisible, ErrVisible, ErrDisabled, ErrNotSelected, ErrOccluded:
+		return true
+	}
+
+	return false
+}
+
+const (
+	// displayedJS determines if this is displayed.
+	//
+	// An element (or, for text nodes, its parent element) is displayed when:
+	//  - it is connected, and is not an input of type hidden or a noscript
+	//    (option and optgroup elements use their enclosing select)
+	//  - neither it nor any ancestor has display none or opacity 0
+	//  - its own computed visibility is visible (visibility is inherited, so
+	//    a visible child of a hidden ancestor is displayed)
+	//  - it, or a descendant, has a box of positive size
+	//  - the box is not entirely clipped by the overflow of an ancestor
+	//  - the box is not entirely at negative document coordinates
+	displayedJS = `function() {
+		var el = this.nodeType === Node.ELEMENT_NODE ? this : this.parentElement;
+		if (!el || !el.isConnected) {
+			return false;
+		}
+		var tag = el.tagName.toLowerCase();
+		if (tag === 'option' || tag === 'optgroup') {
+			el = el.closest('select') || el;
+		}
+		if ((tag === 'input' && el.type === 'hidden') || tag === 'noscript') {
+			return false;
+		}
+		var win = el.ownerDocument.defaultView;
+		var parent = function(e) {
+			var p = e.parentNode;
+			return p && p.nodeType === Node.DOCUMENT_FRAGMENT_NODE && p.host ? p.host : p;
+		};
+		for (var e = el; e && e.nodeType === Node.ELEMENT_NODE; e = parent(e)) {
+			var cs = win.getComputedStyle(e);
+			if (cs.display === 'none' || cs.opacity === '0') {
+				return false;
+			}
+		}
+		if (win.getComputedStyle(el).visibility !== 'visible') {
+			return false;
+		}
+		var sized = function(r) {
+			return r.width > 0 && r.height > 0;
+		};
+		var r = el.getBoundingClientRect();
+		if (!sized(r)) {
+			var all = el.querySelectorAll('*');
+			for (var i = 0; i < all.length && !sized(r); i++) {
+				r = all[i].getBoundingClientRect();
+			}
+			if (!sized(r)) {
+				return false;
+			}
+		}
+		var x1 = r.left, y1 = r.top, x2 = r.right, y2 = r.bottom;
+		for (var e = el; e && e.nodeType === Node.ELEMENT_NODE; e = parent(e)) {
+			var cs = win.getComputedStyle(e);
+			if (e !== el && cs.overflow !== 'visible') {
+				var c = e.getBoundingClientRect();
+				x1 = Math.max(x1, c.left);
+				y1 = Math.max(y1, c.top);
+				x2 = Math.min(x2, c.right);
+				y2 = Math.min(y2, c.bottom);
+				if (x2 <= x1 || y2 <= y1) {
+					return false;
+				}
+			}
+			if (cs.position === 'fixed') {
+				break;
+			}
+		}
+		return x2 + win.pageXOffset > 0 && y2 + win.pageYOffset > 0;
+	}`
+
+	// viewportCenterJS returns the center of the area of this within the
+	// viewport, null when no part of it is within the viewport, or {frame:
+	// true} when this is in a child frame.
+	viewportCenterJS = `function() {
+		var el = this.nodeType === Node.ELEMENT_NODE ? this : this.parentElement;
+		if (!el) {
+			return null;
+		}
+		var win = el.ownerDocument.defaultView;
+		if (win !== win.top) {
+			return {frame: true};
+		}
+		var r = el.getBoundingClientRect();
+		var x1 = Math.max(r.left, 0), y1 = Math.max(r.top, 0);
+		var x2 = Math.min(r.right, win.innerWidth), y2 = Math.min(r.bottom, win.innerHeight);
+		if (x2 <= x1 || y2 <= y1) {
+			return null;
+		}
+		return {x: (x1 + x2) / 2, y: (y1 + y2) / 2};
+	}`
+
+	// nodeTextJS returns the visible text of this.
+	nodeTextJS = `function() {
+		return (this.innerText !== undefined ? this.innerText : this.textContent || '').trim();
+	}`
+
+	// stableJS resolves to true if there are no mutations in the subtree of
+	// this for ms milliseconds, or false on the first mutation.
+	stableJS = `function(ms) {
+		var node = this;
+		return new Promise(function(resolve) {
+			var t;
+			var obs = new MutationObserver(function() {
+				obs.disconnect();
+				clearTimeout(t);
+				resolve(false);
+			});
+			obs.observe(node, {attributes: true, childList: true, characterData: true, subtree: true});
+			t = setTimeout(function() {
+				obs.disconnect();
+				resolve(true);
+			}, ms);
+		});
+	}`
+)
@@ -0,0 +1,140 @@
+package chromedp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/knq/chromedp/cdp"
+	rundom "github.com/knq/chromedp/cdp/runtime"
+)
+
+// ExceptionError is the error returned when a script throws an exception.
+type ExceptionError struct {
+	// Message is the exception message (ie, Uncaught TypeError: x is null).
+	Message string
+
+	// LineNumber and ColumnNumber are the (0-based) location of the
+	// exception.
+	LineNumber, ColumnNumber int64
+
+	// StackTrace is the JavaScript stack trace, if available.
+	StackTrace *rundom.StackTrace
+}
+
+// newExceptionError creates an exception error from the exception details.
+func newExceptionError(exp *rundom.ExceptionDetails) *ExceptionError {
+	msg := exp.Text
+	if e := exp.Exception; e != nil {
+		switch {
+		case e.Description != "":
+			// description holds the error and its stack, one frame per line
+			msg += " " + strings.SplitN(e.Description, "\n", 2)[0]
+		case len(e.Value) != 0:
+			msg += " " + string(e.Value)
+		}
+	}
+
+	return &ExceptionError{
+		Message:      msg,
+		LineNumber:   exp.LineNumber,
+		ColumnNumber: exp.ColumnNumber,
+		StackTrace:   exp.StackTrace,
+	}
+}
+
+// Error satisfies the error interface.
+func (e *ExceptionError) Error() string {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "exception at %d:%d: %s", e.LineNumber, e.ColumnNumber, e.Message)
+	if e.StackTrace != nil {
+		for _, f := range e.StackTrace.CallFrames {
+			name := f.FunctionName
+			if name == "" {
+				name = "<anonymous>"
+			}
+			fmt.Fprintf(buf, "\n    at %s (%s:%d:%d)", name, f.URL, f.LineNumber, f.ColumnNumber)
+		}
+	}
+
+	return buf.String()
+}
+
+// EvaluateOption is an Evaluate option.
+type EvaluateOption func(*rundom.EvaluateParams)
+
+// EvalObjectGroup is an evaluate option to set the object group of the remote
+// object returned by the expression (when res is a **runtime.RemoteObject),
+// so that it can later be released with runtime.ReleaseObjectGroup.
+func EvalObjectGroup(objectGroup string) EvaluateOption {
+	return func(p *rundom.EvaluateParams) {
+		p.ObjectGroup = objectGroup
+	}
+}
+
+// EvalWithCommandLineAPI is an evaluate option to make the DevTools command
+// line API (ie, $, $$, $x) available to the expression.
+func EvalWithCommandLineAPI(p *rundom.EvaluateParams) {
+	p.IncludeCommandLineAPI = true
+}
+
+// EvalAsUserGesture is an evaluate option to evaluate the expression as if it
+// was initiated by the user (ie, so that it can open popups or enter
+// fullscreen).
+func EvalAsUserGesture(p *rundom.EvaluateParams) {
+	p.UserGesture = true
+}
+
+// EvalAwaitPromise is an evaluate option to wait for the promise returned by
+// the expression to be resolved, using the resolved value as the result. A
+// rejected promise is returned as an ExceptionError.
+func EvalAwaitPromise(p *rundom.EvaluateParams) {
+	p.AwaitPromise = true
+}
+
+// Evaluate is an action to evaluate the JavaScript expression, decoding the
+// JSON encoded result into res. When res is a **runtime.RemoteObject, the
+// remote object is stored in res instead.
+//
+// An exception thrown by the expression is returned as an ExceptionError.
+//
+// For example:
+//
+//	var title string
+//	Evaluate(`document.title`, &title)
+func Evaluate(expression string, res interface{}, opts ...EvaluateOption) Action {
+	if res == nil {
+		panic("res cannot be nil")
+	}
+
+	return ActionFunc(func(ctxt context.Context, h cdp.FrameHandler) error {
+		p := rundom.Evaluate(expression)
+		if _, ok := res.(**rundom.RemoteObject); !ok {
+			p.ReturnByValue = true
+		}
+		for _, o := range opts {
+			o(p)
+		}
+
+		v, exp, err := p.Do(ctxt, h)
+		if err != nil {
+			return err
+		}
+		if exp != nil {
+			return newExceptionError(exp)
+		}
+
+		if obj, ok := res.(**rundom.RemoteObject); ok {
+			*obj = v
+			return nil
+		}
+
+		if v.Type == rundom.TypeUndefined || len(v.Value) == 0 {
+			return nil
+		}
+
+		return json.Unmarshal(v.Value, res)
+	})
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"io/ioutil"
 	"log"
 	"time"
@@ -48,7 +47,6 @@ func main() {
 
 func googleSearch(q, text string, site, res *string) cdp.Tasks {
 	var buf []byte
-	sel := fmt.Sprintf(`//a[text()[contains(., '%s')]]`, text)
 	return cdp.Tasks{
 		cdp.Navigate(`https://www.google.com`),
 		cdp.Sleep(2 * time.Second),
@@ -56,8 +54,8 @@ func googleSearch(q, text string, site, res *string) cdp.Tasks {
 		cdp.SendKeys(`#lst-ib`, q, cdp.ByID),
 		cdp.Click(`input[name="btnK"]`, cdp.ByQuery),
 		cdp.WaitNotVisible(`input[name="btnI"]`, cdp.ByQuery),
-		cdp.Text(sel, res),
-		cdp.Click(sel),
+		cdp.Text(text, res, cdp.ByTextContains, cdp.TextTag("a")),
+		cdp.Click(text, cdp.ByTextContains, cdp.TextTag("a")),
 		cdp.Sleep(2 * time.Second),
 		cdp.WaitVisible(`#footer`, cdp.ByQuery),
 		cdp.WaitNotVisible(`div.v-middle > div.la-ball-clip-rotate`, cdp.ByQuery),
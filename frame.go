@@ -0,0 +1,272 @@
+package chromedp
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/knq/chromedp/cdp"
+	"github.com/knq/chromedp/cdp/dom"
+)
+
+// InFrameNamed is a query option to run the selector inside the document of
+// the first iframe or frame whose name (or id) attribute is equal to name.
+func InFrameNamed(name string) QueryOption {
+	return inFrame(func(owner *cdp.Node) bool {
+		return owner.AttributeValue("name") == name || owner.AttributeValue("id") == name
+	})
+}
+
+// InFrameURL is a query option to run the selector inside the document of the
+// first iframe or frame whose document URL matches the regular expression
+// pattern.
+func InFrameURL(pattern string) QueryOption {
+	re := regexp.MustCompile(pattern)
+	return inFrame(func(owner *cdp.Node) bool {
+		return re.MatchString(owner.ContentDocument.DocumentURL)
+	})
+}
+
+// InFrameID is a query option to run the selector inside the document of the
+// iframe or frame with the specified frame id.
+func InFrameID(id cdp.FrameID) QueryOption {
+	return inFrame(func(owner *cdp.Node) bool {
+		return owner.FrameID == id
+	})
+}
+
+// InFrameNode is a query option to run the selector inside the document of
+// the iframe or frame element node (ie, a node previously retrieved with
+// Nodes).
+func InFrameNode(node *cdp.Node) QueryOption {
+	return inFrame(func(owner *cdp.Node) bool {
+		return owner.NodeID == node.NodeID
+	})
+}
+
+// inFrame is a query option to run the selector inside the document of the
+// first frame owner element matching match.
+func inFrame(match func(*cdp.Node) bool) QueryOption {
+	return func(s *Selector) {
+		s.frame = func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) (*cdp.Node, error) {
+			return frameDocument(ctxt, h, n, match)
+		}
+	}
+}
+
+// frameDocument searches n, and the documents of any nested frames, for the
+// first frame owner element (ie, iframe or frame) matching match, returning
+// its content document. Returns nil if no frame owner matched, or the frame's
+// document is not yet available.
+//
+// match is called with the frame locked for reading, and only for owners with
+// a content document.
+func frameDocument(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node, match func(*cdp.Node) bool) (*cdp.Node, error) {
+	f, err := h.WaitFrame(ctxt, emptyFrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := []*cdp.Node{n}
+	for len(queue) > 0 {
+		doc := queue[0]
+		queue = queue[1:]
+
+		ids, err := dom.QuerySelectorAll(doc.NodeID, "iframe, frame").Do(ctxt, h)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			owner, err := h.WaitNode(ctxt, f, id)
+			if err != nil {
+				return nil, err
+			}
+
+			f.RLock()
+			content := owner.ContentDocument
+			ok := content != nil && match(owner)
+			f.RUnlock()
+
+			switch {
+			case ok:
+				return content, nil
+			case content != nil:
+				queue = append(queue, content)
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// FrameInfo holds information about a frame in a target's frame tree.
+type FrameInfo struct {
+	ID       cdp.FrameID
+	ParentID cdp.FrameID
+	Name     string
+	URL      string
+	State    cdp.FrameState
+	Children []*FrameInfo
+}
+
+// newFrameInfo creates the frame information for f, without its children.
+func newFrameInfo(f *cdp.Frame) *FrameInfo {
+	f.RLock()
+	defer f.RUnlock()
+
+	return &FrameInfo{
+		ID:       f.ID,
+		ParentID: f.ParentID,
+		Name:     f.Name,
+		URL:      f.URL,
+		State:    f.State,
+	}
+}
+
+// FrameMatch is a func that matches a frame.
+type FrameMatch func(*FrameInfo) bool
+
+// FrameNamed matches frames with the specified name.
+func FrameNamed(name string) FrameMatch {
+	return func(fi *FrameInfo) bool {
+		return fi.Name == name
+	}
+}
+
+// FrameURL matches frames whose URL matches the regular expression pattern.
+func FrameURL(pattern string) FrameMatch {
+	re := regexp.MustCompile(pattern)
+	return func(fi *FrameInfo) bool {
+		return re.MatchString(fi.URL)
+	}
+}
+
+// FrameTree returns the target's current frame tree, starting at the top
+// level frame. Detached frames are not included.
+func (h *TargetHandler) FrameTree() *FrameInfo {
+	h.RLock()
+	defer h.RUnlock()
+
+	if h.cur == nil {
+		return nil
+	}
+
+	infos := make(map[cdp.FrameID]*FrameInfo)
+	for id, f := range h.frames {
+		infos[id] = newFrameInfo(f)
+	}
+
+	root := infos[h.cur.ID]
+	for _, fi := range infos {
+		if p, ok := infos[fi.ParentID]; ok && fi != root {
+			p.Children = append(p.Children, fi)
+		}
+	}
+
+	return root
+}
+
+// waitFrameState waits for an attached frame matching match to have all the
+// bits of state set, returning its information.
+func (h *TargetHandler) waitFrameState(ctxt context.Context, match FrameMatch, state cdp.FrameState) (*FrameInfo, error) {
+	var res *FrameInfo
+	err := h.wait(ctxt, func() bool {
+		for _, f := range h.frames {
+			fi := newFrameInfo(f)
+			if f != h.cur && fi.ParentID == emptyFrameID {
+				// detached
+				continue
+			}
+
+			if match(fi) && fi.State&state == state {
+				res = fi
+				return true
+			}
+		}
+
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// FrameTree is an action to retrieve the target's current frame tree (see
+// TargetHandler.FrameTree).
+func FrameTree(tree **FrameInfo) Action {
+	if tree == nil {
+		panic("tree cannot be nil")
+	}
+
+	return ActionFunc(func(ctxt context.Context, h cdp.FrameHandler) error {
+		th, ok := h.(*TargetHandler)
+		if !ok {
+			return ErrNoFrames
+		}
+
+		*tree = th.FrameTree()
+		return nil
+	})
+}
+
+// WaitFrameState is an action that waits for a frame matching match to have
+// all the bits of state set (ie, cdp.FrameNavigated), storing the frame's id
+// in id when not nil.
+func WaitFrameState(match FrameMatch, state cdp.FrameState, id *cdp.FrameID) Action {
+	return ActionFunc(func(ctxt context.Context, h cdp.FrameHandler) error {
+		th, ok := h.(*TargetHandler)
+		if !ok {
+			return ErrNoFrames
+		}
+
+		fi, err := th.waitFrameState(ctxt, match, state)
+		if err != nil {
+			return err
+		}
+
+		if id != nil {
+			*id = fi.ID
+		}
+		return nil
+	})
+}
+
+// SwitchFrame is an action that waits for a frame matching match to be
+// navigated, and then makes it the active frame, so that subsequent queries
+// run inside the frame's document.
+func SwitchFrame(match FrameMatch) Action {
+	return ActionFunc(func(ctxt context.Context, h cdp.FrameHandler) error {
+		th, ok := h.(*TargetHandler)
+		if !ok {
+			return ErrNoFrames
+		}
+
+		fi, err := th.waitFrameState(ctxt, match, cdp.FrameNavigated)
+		if err != nil {
+			return err
+		}
+
+		return h.SetActive(ctxt, fi.ID)
+	})
+}
+
+// SwitchToTopFrame is an action that makes the top level frame the active
+// frame again.
+func SwitchToTopFrame() Action {
+	return ActionFunc(func(ctxt context.Context, h cdp.FrameHandler) error {
+		th, ok := h.(*TargetHandler)
+		if !ok {
+			return ErrNoFrames
+		}
+
+		th.Lock()
+		defer th.Unlock()
+
+		th.active = emptyFrameID
+		th.notifyChanged()
+
+		return nil
+	})
+}
@@ -2,12 +2,14 @@ package chromedp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mailru/easyjson"
@@ -26,8 +28,32 @@ var (
 	_ cdp.FrameHandler = &TargetHandler{}
 )
 
+// Error types.
+var (
+	ErrTargetCrashed = errors.New("target crashed")
+	ErrTargetClosed  = errors.New("target closed")
+)
+
+// TargetDetachedError is the error returned when the target was detached
+// from the handler (ie, when another client attached to it).
+type TargetDetachedError struct {
+	Reason inspector.DetachReason
+}
+
+// Error satisfies the error interface.
+func (e *TargetDetachedError) Error() string {
+	if e.Reason == "" {
+		return "target detached"
+	}
+
+	return fmt.Sprintf("target detached: %s", e.Reason)
+}
+
 // TargetHandler manages a Chrome Debugging Protocol target.
 type TargetHandler struct {
+	// target is the client target.
+	target client.Target
+
 	conn client.Transport
 
 	// frames is the set of encountered frames.
@@ -36,6 +62,17 @@ type TargetHandler struct {
 	// cur is the current top level frame.
 	cur *cdp.Frame
 
+	// active is the child frame selectors run in, or empty for the top level
+	// frame.
+	active cdp.FrameID
+
+	// changed is closed, and replaced, each time a frame or node changes.
+	changed chan struct{}
+
+	// queryTimeout and pollInterval are the defaults for queries run against
+	// the handler.
+	queryTimeout, pollInterval time.Duration
+
 	// qcmd is the outgoing message queue.
 	qcmd chan *cdp.Message
 
@@ -45,10 +82,33 @@ type TargetHandler struct {
 	// qevents is the incoming event queue.
 	qevents chan *cdp.Message
 
-	// detached is closed when the detached event is received.
-	detached chan *inspector.EventDetached
+	// term is closed when the handler reaches its terminal state, after
+	// which err is set.
+	term  chan struct{}
+	err   error
+	termm sync.Mutex
+
+	// pageq is the page event queue, which is applied in order by a single
+	// worker, so that the frame state follows the order of the events.
+	pageq chan interface{}
+
+	domWaitGroup *sync.WaitGroup
 
-	pageWaitGroup, domWaitGroup *sync.WaitGroup
+	// domCtxt is the context DOM events are handled with, which is cancelled
+	// by domCancel each time the document is updated, releasing handlers
+	// still waiting on nodes of the old document. Only used by the run loop.
+	domCtxt   context.Context
+	domCancel context.CancelFunc
+
+	// docSeq is the sequence number of the last document update, so that a
+	// retrieved document does not replace a more recently updated one.
+	docSeq int64
+
+	// cancel stops the run loop.
+	cancel context.CancelFunc
+
+	// done is closed when the run loop has finished.
+	done chan struct{}
 
 	// last is the last sent message identifier.
 	last  int64
@@ -58,9 +118,22 @@ type TargetHandler struct {
 	res   map[int64]chan interface{}
 	resrw sync.RWMutex
 
+	// listeners are the registered event listeners.
+	listeners  []*listener
+	listenersm sync.Mutex
+
 	sync.RWMutex
 }
 
+// listener is a registered event listener.
+type listener struct {
+	// types is the set of event types to send, or nil for all event types.
+	types map[cdp.MethodType]bool
+
+	in   chan interface{}
+	done <-chan struct{}
+}
+
 // NewTargetHandler creates a new manager for the specified client target.
 func NewTargetHandler(t client.Target) (*TargetHandler, error) {
 	conn, err := client.Dial(t)
@@ -68,26 +141,41 @@ func NewTargetHandler(t client.Target) (*TargetHandler, error) {
 		return nil, err
 	}
 
-	return &TargetHandler{conn: conn}, nil
+	return NewTargetHandlerWithTransport(t, conn), nil
+}
+
+// NewTargetHandlerWithTransport creates a new manager for the specified
+// client target, sending and receiving messages over the supplied transport
+// (such as a target session attached through a Browser).
+func NewTargetHandlerWithTransport(t client.Target, conn client.Transport) *TargetHandler {
+	return &TargetHandler{
+		target: t,
+		conn:   conn,
+	}
 }
 
 // Run starts the processing of commands and events to the client target
 // provided to NewTargetHandler.
 //
-// Callers can stop Run by closing the passed context.
+// Callers can stop Run by closing the passed context, or by calling Close.
 func (h *TargetHandler) Run(ctxt context.Context) error {
 	var err error
 
 	// reset
 	h.Lock()
+	ctxt, h.cancel = context.WithCancel(ctxt)
+	h.done = make(chan struct{})
 	h.frames = make(map[cdp.FrameID]*cdp.Frame)
+	h.changed = make(chan struct{})
 	h.qcmd = make(chan *cdp.Message)
 	h.qres = make(chan *cdp.Message)
 	h.qevents = make(chan *cdp.Message)
 	h.res = make(map[int64]chan interface{})
-	h.detached = make(chan *inspector.EventDetached)
-	h.pageWaitGroup = new(sync.WaitGroup)
+	h.pageq = make(chan interface{})
 	h.domWaitGroup = new(sync.WaitGroup)
+	h.termm.Lock()
+	h.term, h.err = make(chan struct{}), nil
+	h.termm.Unlock()
 	h.Unlock()
 
 	// run
@@ -109,36 +197,50 @@ func (h *TargetHandler) Run(ctxt context.Context) error {
 		}
 	}
 
-	h.Lock()
-
 	// get page resources
 	tree, err := page.GetResourceTree().Do(ctxt, h)
 	if err != nil {
 		return fmt.Errorf("unable to get resource tree, got: %v", err)
 	}
 
-	h.frames[tree.Frame.ID] = tree.Frame
+	h.Lock()
+	h.addFrames(tree)
 	h.cur = tree.Frame
-
-	for _, c := range tree.ChildFrames {
-		h.frames[c.Frame.ID] = c.Frame
-	}
-
+	h.notifyChanged()
 	h.Unlock()
 
-	h.documentUpdated(ctxt)
+	h.documentUpdated(ctxt, atomic.AddInt64(&h.docSeq, 1))
 
 	return nil
 }
 
+// addFrames adds the frames in the resource tree, which have already been
+// navigated. h must be locked.
+func (h *TargetHandler) addFrames(tree *page.FrameResourceTree) {
+	setFrameState(tree.Frame, cdp.FrameNavigated)
+	h.frames[tree.Frame.ID] = tree.Frame
+
+	for _, c := range tree.ChildFrames {
+		h.addFrames(c)
+	}
+}
+
 // run handles the actual message processing to / from the web socket connection.
 func (h *TargetHandler) run(ctxt context.Context) {
+	defer close(h.done)
 	defer h.conn.Close()
+	defer h.terminate(ErrTargetClosed)
 
 	// add cancel to context
 	ctxt, cancel := context.WithCancel(ctxt)
 	defer cancel()
 
+	h.domCtxt, h.domCancel = context.WithCancel(ctxt)
+
+	pages := make(chan interface{})
+	go forward(ctxt, h.pageq, pages)
+	go h.pageEvents(pages)
+
 	go func() {
 		defer cancel()
 
@@ -149,21 +251,28 @@ func (h *TargetHandler) run(ctxt context.Context) {
 				if err != nil {
 					return
 				}
+				if msg == nil {
+					continue
+				}
 
+				var q chan *cdp.Message
 				switch {
 				case msg.Method != "":
-					h.qevents <- msg
+					q = h.qevents
 
 				case msg.ID != 0:
-					h.qres <- msg
+					q = h.qres
 
 				default:
 					log.Printf("ignoring malformed incoming message (missing id or method): %#v", msg)
+					continue
 				}
 
-			case <-h.detached:
-				// FIXME: should log when detached, and reason
-				return
+				select {
+				case q <- msg:
+				case <-ctxt.Done():
+					return
+				}
 
 			case <-ctxt.Done():
 				return
@@ -194,13 +303,62 @@ func (h *TargetHandler) run(ctxt context.Context) {
 				log.Printf("could not process command, got: %v", err)
 			}
 
+		case <-h.term:
+			return
+
 		case <-ctxt.Done():
 			return
 		}
 	}
 }
 
-// read reads a message from the client connection.
+// terminate puts the handler in its terminal state, failing all pending and
+// future commands and waits with err. Only the first call has any effect.
+func (h *TargetHandler) terminate(err error) {
+	h.termm.Lock()
+	defer h.termm.Unlock()
+
+	if h.err != nil {
+		return
+	}
+
+	h.err = err
+	close(h.term)
+}
+
+// Err returns the error the handler terminated with (ie, ErrTargetCrashed or
+// a *TargetDetachedError), or nil if the handler is still running.
+func (h *TargetHandler) Err() error {
+	h.termm.Lock()
+	defer h.termm.Unlock()
+
+	return h.err
+}
+
+// Close stops the processing of commands and events for the target, and
+// waits for the underlying client connection to be closed.
+func (h *TargetHandler) Close() error {
+	h.RLock()
+	cancel, done := h.cancel, h.done
+	h.RUnlock()
+
+	if cancel == nil {
+		return h.conn.Close()
+	}
+
+	cancel()
+	<-done
+
+	return nil
+}
+
+// GetTarget returns the client target for the handler.
+func (h *TargetHandler) GetTarget() client.Target {
+	return h.target
+}
+
+// read reads a message from the client connection, returning a nil message
+// when the message could not be decoded.
 func (h *TargetHandler) read() (*cdp.Message, error) {
 	// read
 	buf, err := h.conn.Read()
@@ -210,11 +368,13 @@ func (h *TargetHandler) read() (*cdp.Message, error) {
 
 	log.Printf("-> %s", string(buf))
 
-	// unmarshal
+	// unmarshal, skipping messages that cannot be decoded (ie, events not
+	// known to the cdp package) instead of stopping the handler
 	msg := new(cdp.Message)
 	err = easyjson.Unmarshal(buf, msg)
 	if err != nil {
-		return nil, err
+		log.Printf("ignoring undecodable incoming message, got: %v", err)
+		return nil, nil
 	}
 
 	return msg, nil
@@ -232,16 +392,27 @@ func (h *TargetHandler) processEvent(ctxt context.Context, msg *cdp.Message) err
 		return err
 	}
 
+	h.notify(msg.Method, ev)
+
 	switch e := ev.(type) {
 	case *inspector.EventDetached:
-		h.Lock()
-		defer h.Unlock()
-		h.detached <- e
+		log.Printf("error: target %s detached, reason: %s", h.target, e.Reason)
+		h.terminate(&TargetDetachedError{Reason: e.Reason})
+		return nil
+
+	case *inspector.EventTargetCrashed:
+		log.Printf("error: target %s crashed", h.target)
+		h.terminate(ErrTargetCrashed)
 		return nil
 
 	case *dom.EventDocumentUpdated:
+		// release pending DOM events waiting on nodes of the old document,
+		// without locking, as the run loop must never block on a lock that
+		// may be held while waiting on a command result
+		h.domCancel()
 		h.domWaitGroup.Wait()
-		go h.documentUpdated(ctxt)
+		h.domCtxt, h.domCancel = context.WithCancel(ctxt)
+		go h.documentUpdated(ctxt, atomic.AddInt64(&h.docSeq, 1))
 		return nil
 	}
 
@@ -252,42 +423,65 @@ func (h *TargetHandler) processEvent(ctxt context.Context, msg *cdp.Message) err
 
 	switch d {
 	case "Page":
-		h.pageWaitGroup.Add(1)
-		go h.pageEvent(ctxt, ev)
+		select {
+		case h.pageq <- ev:
+		case <-ctxt.Done():
+		}
 
 	case "DOM":
 		h.domWaitGroup.Add(1)
-		go h.domEvent(ctxt, ev)
+		go h.domEvent(h.domCtxt, ev)
 	}
 
 	return nil
 }
 
 // documentUpdated handles the document updated event, retrieving the document
-// root for the root frame.
-func (h *TargetHandler) documentUpdated(ctxt context.Context) {
+// root for the root frame. seq is the sequence number of the document update.
+func (h *TargetHandler) documentUpdated(ctxt context.Context, seq int64) {
 	f, err := h.WaitFrame(ctxt, emptyFrameID)
 	if err != nil {
 		log.Printf("could not get current frame, got: %v", err)
 		return
 	}
 
-	f.Lock()
-	defer f.Unlock()
-
 	// invalidate nodes
-	if f.Root != nil {
-		close(f.Root.Invalidated)
-	}
+	h.setRoot(f, seq, nil)
 
-	f.Nodes = make(map[cdp.NodeID]*cdp.Node)
-	f.Root, err = dom.GetDocument().WithPierce(true).Do(ctxt, h)
+	// retrieve document, without holding any lock while waiting on the result
+	root, err := dom.GetDocument().WithPierce(true).Do(ctxt, h)
 	if err != nil {
 		log.Printf("error could not retrieve document root for %s, got: %v", f.ID, err)
 		return
 	}
-	f.Root.Invalidated = make(chan struct{})
-	walk(f.Nodes, f.Root)
+	root.Invalidated = make(chan struct{})
+
+	h.setRoot(f, seq, root)
+}
+
+// setRoot replaces the frame's root document node (invalidating the old root),
+// unless a later document update has superseded seq.
+func (h *TargetHandler) setRoot(f *cdp.Frame, seq int64, root *cdp.Node) {
+	nodes := make(map[cdp.NodeID]*cdp.Node)
+	if root != nil {
+		walk(nodes, root)
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	if atomic.LoadInt64(&h.docSeq) != seq {
+		return
+	}
+
+	f.Lock()
+	if f.Root != nil {
+		close(f.Root.Invalidated)
+	}
+	f.Root, f.Nodes = root, nodes
+	f.Unlock()
+
+	h.notifyChanged()
 }
 
 // processResult processes an incoming command result.
@@ -295,9 +489,11 @@ func (h *TargetHandler) processResult(msg *cdp.Message) error {
 	h.resrw.Lock()
 	defer h.resrw.Unlock()
 
+	// the command may have been abandoned (ie, its context was cancelled)
 	res, ok := h.res[msg.ID]
 	if !ok {
-		panic(fmt.Sprintf("expected result to be present for message id %d", msg.ID))
+		log.Printf("ignoring result for unknown message id %d", msg.ID)
+		return nil
 	}
 
 	if msg.Error != nil {
@@ -312,18 +508,28 @@ func (h *TargetHandler) processResult(msg *cdp.Message) error {
 }
 
 // processCommand writes a command to the client connection.
+//
+// When the command cannot be marshaled or written, the error is also passed to
+// the command's result channel, so that Execute does not wait for a result
+// that will never be received.
 func (h *TargetHandler) processCommand(cmd *cdp.Message) error {
-	// FIXME: there are two possible error conditions here, check and
-	// do some kind of logging ...
 	buf, err := easyjson.Marshal(cmd)
+	if err == nil {
+		log.Printf("<- %s", string(buf))
+
+		// write
+		err = h.conn.Write(buf)
+	}
 	if err != nil {
-		return err
+		h.resrw.Lock()
+		if res, ok := h.res[cmd.ID]; ok {
+			res <- err
+			delete(h.res, cmd.ID)
+		}
+		h.resrw.Unlock()
 	}
 
-	log.Printf("<- %s", string(buf))
-
-	// write
-	return h.conn.Write(buf)
+	return err
 }
 
 // Execute executes commandType against the endpoint passed to Run, using the
@@ -340,11 +546,17 @@ func (h *TargetHandler) processCommand(cmd *cdp.Message) error {
 func (h *TargetHandler) Execute(ctxt context.Context, commandType cdp.MethodType, params easyjson.RawMessage) <-chan interface{} {
 	ch := make(chan interface{}, 1)
 
+	// fail fast when terminated
+	if err := h.Err(); err != nil {
+		ch <- err
+		close(ch)
+		return ch
+	}
+
 	go func() {
 		defer close(ch)
 
 		res := make(chan interface{}, 1)
-		defer close(res)
 
 		// get next id
 		h.lastm.Lock()
@@ -352,15 +564,31 @@ func (h *TargetHandler) Execute(ctxt context.Context, commandType cdp.MethodType
 		id := h.last
 		h.lastm.Unlock()
 
-		// save channel
+		// save channel, removing it once done, so that late results are
+		// ignored
 		h.resrw.Lock()
 		h.res[id] = res
 		h.resrw.Unlock()
+		defer func() {
+			h.resrw.Lock()
+			delete(h.res, id)
+			h.resrw.Unlock()
+		}()
 
-		h.qcmd <- &cdp.Message{
+		select {
+		case h.qcmd <- &cdp.Message{
 			ID:     id,
 			Method: commandType,
 			Params: params,
+		}:
+
+		case <-h.term:
+			ch <- h.Err()
+			return
+
+		case <-ctxt.Done():
+			ch <- cdp.ErrContextDone
+			return
 		}
 
 		select {
@@ -371,6 +599,9 @@ func (h *TargetHandler) Execute(ctxt context.Context, commandType cdp.MethodType
 				ch <- cdp.ErrChannelClosed
 			}
 
+		case <-h.term:
+			ch <- h.Err()
+
 		case <-ctxt.Done():
 			ch <- cdp.ErrContextDone
 		}
@@ -379,49 +610,163 @@ func (h *TargetHandler) Execute(ctxt context.Context, commandType cdp.MethodType
 	return ch
 }
 
-// Listen adds a listener for the specified event types to the appropriate
-// domain.
-func (h *TargetHandler) Listen(eventTypes ...cdp.MethodType) <-chan interface{} {
-	return nil
+// Listen returns a channel that receives the decoded events (for example,
+// *network.EventResponseReceived) for the specified event types, or for all
+// events when no event types are specified. Events are queued, so that a slow
+// receiver never blocks the handler.
+//
+// The listener is removed, and the returned channel closed, when the passed
+// context is done or the handler stops.
+//
+// Note: Chrome only sends events for enabled domains (see network.Enable).
+func (h *TargetHandler) Listen(ctxt context.Context, eventTypes ...cdp.MethodType) <-chan interface{} {
+	h.RLock()
+	done := h.done
+	h.RUnlock()
+
+	// stop listening when the handler stops
+	ctxt, cancel := context.WithCancel(ctxt)
+	go func() {
+		defer cancel()
+
+		select {
+		case <-done:
+		case <-ctxt.Done():
+		}
+	}()
+
+	l := &listener{
+		in:   make(chan interface{}),
+		done: ctxt.Done(),
+	}
+	if len(eventTypes) != 0 {
+		l.types = make(map[cdp.MethodType]bool)
+		for _, typ := range eventTypes {
+			l.types[typ] = true
+		}
+	}
+
+	ch := make(chan interface{})
+	go forward(ctxt, l.in, ch)
+
+	h.listenersm.Lock()
+	h.listeners = append(h.listeners, l)
+	h.listenersm.Unlock()
+
+	return ch
 }
 
-// GetRoot returns the current top level frame's root document node.
-func (h *TargetHandler) GetRoot(ctxt context.Context) (*cdp.Node, error) {
-	// TODO: fix this
-	ctxt, cancel := context.WithTimeout(ctxt, 10*time.Second)
-	defer cancel()
+// notify sends the event to the listeners for the event type, removing any
+// listeners that are done.
+func (h *TargetHandler) notify(typ cdp.MethodType, ev interface{}) {
+	h.listenersm.Lock()
+	defer h.listenersm.Unlock()
 
-	var root *cdp.Node
+	listeners := h.listeners[:0]
+	for _, l := range h.listeners {
+		if l.types != nil && !l.types[typ] {
+			select {
+			case <-l.done:
+			default:
+				listeners = append(listeners, l)
+			}
+			continue
+		}
 
-loop:
+		select {
+		case l.in <- ev:
+			listeners = append(listeners, l)
+		case <-l.done:
+		}
+	}
+	h.listeners = listeners
+}
+
+// notifyChanged wakes all waiters, after a frame or node has changed. h must
+// be locked.
+func (h *TargetHandler) notifyChanged() {
+	close(h.changed)
+	h.changed = make(chan struct{})
+}
+
+// wait waits until check returns true, re-checking each time a frame or node
+// changes. check is called with h read locked.
+func (h *TargetHandler) wait(ctxt context.Context, check func() bool) error {
 	for {
-		var cur *cdp.Frame
+		h.RLock()
+		ok, changed := check(), h.changed
+		h.RUnlock()
+		if ok {
+			return nil
+		}
+
 		select {
-		default:
-			h.RLock()
-			cur = h.cur
-			if cur != nil {
-				cur.RLock()
-				root = cur.Root
-				cur.RUnlock()
-			}
-			h.RUnlock()
+		case <-changed:
+
+		case <-h.term:
+			return h.Err()
+
+		case <-ctxt.Done():
+			return cdp.ErrContextDone
+		}
+	}
+}
 
-			if cur != nil && root != nil {
-				break loop
+// GetRoot returns the active frame's root document node. For a child frame
+// made active with SetActive, this is the content document of its frame owner
+// element.
+func (h *TargetHandler) GetRoot(ctxt context.Context) (*cdp.Node, error) {
+	for {
+		var root *cdp.Node
+		var active cdp.FrameID
+		err := h.wait(ctxt, func() bool {
+			if h.cur == nil {
+				return false
 			}
 
-			time.Sleep(DefaultCheckDuration)
+			h.cur.RLock()
+			defer h.cur.RUnlock()
+			root, active = h.cur.Root, h.active
+
+			return root != nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if active == emptyFrameID {
+			return root, nil
+		}
+
+		h.RLock()
+		changed := h.changed
+		h.RUnlock()
+
+		doc, err := frameDocument(ctxt, h, root, func(owner *cdp.Node) bool {
+			return owner.FrameID == active
+		})
+		if err != nil {
+			return nil, err
+		}
+		if doc != nil {
+			return doc, nil
+		}
+
+		// wait for the frame's document
+		select {
+		case <-changed:
+
+		case <-h.term:
+			return nil, h.Err()
 
 		case <-ctxt.Done():
-			return nil, ctxt.Err()
+			return nil, cdp.ErrContextDone
 		}
 	}
-
-	return root, nil
 }
 
-// SetActive sets the currently active frame after a successful navigation.
+// SetActive sets the currently active frame after a successful navigation,
+// or switches selectors to run in the specified child frame.
 func (h *TargetHandler) SetActive(ctxt context.Context, id cdp.FrameID) error {
 	var err error
 
@@ -431,102 +776,105 @@ func (h *TargetHandler) SetActive(ctxt context.Context, id cdp.FrameID) error {
 		return err
 	}
 
+	f.RLock()
+	child := f.ParentID != emptyFrameID
+	f.RUnlock()
+
 	h.Lock()
 	defer h.Unlock()
 
-	h.cur = f
+	if child {
+		h.active = id
+	} else {
+		h.cur, h.active = f, emptyFrameID
+	}
+	h.notifyChanged()
 
 	return nil
 }
 
 // WaitFrame waits for a frame to be loaded using the provided context.
 func (h *TargetHandler) WaitFrame(ctxt context.Context, id cdp.FrameID) (*cdp.Frame, error) {
-	// TODO: fix this
-	timeout := time.After(10 * time.Second)
-
-loop:
-	for {
-		select {
-		default:
-			var f *cdp.Frame
-			var ok bool
-
-			h.RLock()
-			if id == emptyFrameID {
-				f, ok = h.cur, h.cur != nil
-			} else {
-				f, ok = h.frames[id]
-			}
-			h.RUnlock()
-
-			if ok {
-				return f, nil
-			}
-
-			time.Sleep(DefaultCheckDuration)
-
-		case <-ctxt.Done():
-			return nil, cdp.ErrContextDone
-
-		case <-timeout:
-			break loop
+	var f *cdp.Frame
+	err := h.wait(ctxt, func() bool {
+		if id == emptyFrameID {
+			f = h.cur
+		} else {
+			f = h.frames[id]
 		}
+
+		return f != nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, fmt.Errorf("timeout waiting for frame `%s`", id)
+	return f, nil
 }
 
 // WaitNode waits for a node to be loaded using the provided context.
 func (h *TargetHandler) WaitNode(ctxt context.Context, f *cdp.Frame, id cdp.NodeID) (*cdp.Node, error) {
-	// TODO: fix this
-	timeout := time.After(10 * time.Second)
-
-loop:
-	for {
-		select {
-		default:
-			var n *cdp.Node
-			var ok bool
+	var n *cdp.Node
+	err := h.wait(ctxt, func() bool {
+		f.RLock()
+		defer f.RUnlock()
+		n = f.Nodes[id]
+
+		return n != nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-			f.RLock()
-			n, ok = f.Nodes[id]
-			f.RUnlock()
+	return n, nil
+}
 
-			if n != nil && ok {
-				return n, nil
-			}
+// waitLoaded waits until the active frame has navigated away from the
+// specified loader and fired its load event.
+func (h *TargetHandler) waitLoaded(ctxt context.Context, loaderID cdp.LoaderID) error {
+	return h.wait(ctxt, func() bool {
+		if h.cur == nil {
+			return false
+		}
 
-			time.Sleep(DefaultCheckDuration)
+		h.cur.RLock()
+		defer h.cur.RUnlock()
 
-		case <-ctxt.Done():
-			return nil, cdp.ErrContextDone
+		return h.cur.LoaderID != loaderID && h.cur.State&cdp.FrameLoadEventFired != 0
+	})
+}
 
-		case <-timeout:
-			break loop
-		}
+// pageEvents applies the queued page events, in the order they were received,
+// until events is closed.
+func (h *TargetHandler) pageEvents(events <-chan interface{}) {
+	for ev := range events {
+		h.pageEvent(ev)
 	}
-
-	return nil, fmt.Errorf("timeout waiting for node `%d`", id)
 }
 
 // pageEvent handles incoming page events.
-func (h *TargetHandler) pageEvent(ctxt context.Context, ev interface{}) {
-	defer h.pageWaitGroup.Done()
-
+func (h *TargetHandler) pageEvent(ev interface{}) {
 	var id cdp.FrameID
 	var op FrameOp
 
 	switch e := ev.(type) {
 	case *page.EventFrameNavigated:
 		h.Lock()
-		h.frames[e.Frame.ID] = e.Frame
+		if _, ok := h.frames[e.Frame.ID]; !ok {
+			h.frames[e.Frame.ID] = e.Frame
+		}
 		h.Unlock()
-		return
+		id, op = e.Frame.ID, frameNavigated(e.Frame)
 
 	case *page.EventFrameAttached:
 		id, op = e.FrameID, frameAttached(e.ParentFrameID)
 
 	case *page.EventFrameDetached:
+		h.Lock()
+		if h.active == e.FrameID {
+			h.active = emptyFrameID
+		}
+		h.Unlock()
 		id, op = e.FrameID, frameDetached
 
 	case *page.EventFrameStartedLoading:
@@ -542,29 +890,40 @@ func (h *TargetHandler) pageEvent(ctxt context.Context, ev interface{}) {
 		id, op = e.FrameID, frameClearedScheduledNavigation
 
 	case *page.EventDomContentEventFired:
-		return
+		id, op = emptyFrameID, domContentEventFired
+
 	case *page.EventLoadEventFired:
-		return
+		id, op = emptyFrameID, loadEventFired
+
 	case *page.EventFrameResized:
 		return
 
 	default:
-		panic(fmt.Sprintf("unhandled page event %s", reflect.TypeOf(ev)))
-	}
-
-	f, err := h.WaitFrame(ctxt, id)
-	if err != nil {
-		log.Printf("error could not get frame %s, got: %v", id, err)
+		// other page events (ie, dialogs and screencast frames) do not
+		// change the frame state, and are only sent to listeners
 		return
 	}
 
 	h.Lock()
 	defer h.Unlock()
 
-	f.Lock()
-	defer f.Unlock()
+	// frames are only known once navigated (or loaded with the resource
+	// tree), so events for unknown frames are ignored, as waiting on them
+	// would hold up the events that follow
+	f := h.cur
+	if id != emptyFrameID {
+		f = h.frames[id]
+	}
+	if f == nil {
+		h.notifyChanged()
+		return
+	}
 
+	f.Lock()
 	op(f)
+	f.Unlock()
+
+	h.notifyChanged()
 }
 
 // domEvent handles incoming DOM events.
@@ -578,6 +937,19 @@ func (h *TargetHandler) domEvent(ctxt context.Context, ev interface{}) {
 		return
 	}
 
+	// wait current root (of the top level frame, not the active frame, as
+	// GetRoot may issue commands for a child frame's document)
+	err = h.wait(ctxt, func() bool {
+		f.RLock()
+		defer f.RUnlock()
+
+		return f.Root != nil
+	})
+	if err != nil {
+		log.Printf("error processing DOM event %s: error waiting for root, got: %v", reflect.TypeOf(ev), err)
+		return
+	}
+
 	var id cdp.NodeID
 	var op NodeOp
 
@@ -634,7 +1006,9 @@ func (h *TargetHandler) domEvent(ctxt context.Context, ev interface{}) {
 		return
 
 	default:
-		panic(fmt.Sprintf("unhandled node event %s", reflect.TypeOf(ev)))
+		// other DOM events do not change the mirrored node state, and are
+		// only sent to listeners
+		return
 	}
 
 	s := strings.TrimPrefix(strings.TrimSuffix(runtime.FuncForPC(reflect.ValueOf(op).Pointer()).Name(), ".func1"), "github.com/knq/chromedp.")
@@ -650,10 +1024,10 @@ func (h *TargetHandler) domEvent(ctxt context.Context, ev interface{}) {
 	defer h.Unlock()
 
 	f.Lock()
-	defer f.Unlock()
-
 	n.Lock()
-	defer n.Unlock()
-
 	op(n)
+	n.Unlock()
+	f.Unlock()
+
+	h.notifyChanged()
 }
@@ -0,0 +1,233 @@
+package chromedp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mailru/easyjson"
+
+	"github.com/knq/chromedp/cdp"
+	"github.com/knq/chromedp/cdp/inspector"
+)
+
+// testTransport is a client.Transport that records written messages, and
+// reads the messages passed to in.
+type testTransport struct {
+	in      chan []byte
+	written chan []byte
+
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newTestTransport() *testTransport {
+	return &testTransport{
+		in:      make(chan []byte),
+		written: make(chan []byte, 16),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (t *testTransport) Read() ([]byte, error) {
+	select {
+	case buf := <-t.in:
+		return buf, nil
+	case <-t.closed:
+		return nil, io.EOF
+	}
+}
+
+func (t *testTransport) Write(buf []byte) error {
+	select {
+	case t.written <- buf:
+		return nil
+	case <-t.closed:
+		return io.ErrClosedPipe
+	}
+}
+
+func (t *testTransport) Close() error {
+	t.once.Do(func() {
+		close(t.closed)
+	})
+	return nil
+}
+
+// send passes msg to the handler reading from the transport.
+func (t *testTransport) send(tt *testing.T, msg string) {
+	select {
+	case t.in <- []byte(msg):
+	case <-time.After(time.Second):
+		tt.Fatalf("timeout sending %s", msg)
+	}
+}
+
+// next returns the id of the next command written to the transport.
+func (t *testTransport) next(tt *testing.T) int64 {
+	select {
+	case buf := <-t.written:
+		var msg struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.Unmarshal(buf, &msg); err != nil {
+			tt.Fatalf("could not decode written message %s, got: %v", string(buf), err)
+		}
+		return msg.ID
+
+	case <-time.After(time.Second):
+		tt.Fatal("timeout waiting for command")
+	}
+	return 0
+}
+
+// newTestHandler starts the run loop of a handler for the transport, without
+// enabling any domains (as Run does).
+func newTestHandler(t *testing.T, conn *testTransport) *TargetHandler {
+	h := NewTargetHandlerWithTransport(nil, conn)
+
+	var ctxt context.Context
+	ctxt, h.cancel = context.WithCancel(context.Background())
+	h.done = make(chan struct{})
+	h.frames = make(map[cdp.FrameID]*cdp.Frame)
+	h.changed = make(chan struct{})
+	h.qcmd = make(chan *cdp.Message)
+	h.qres = make(chan *cdp.Message)
+	h.qevents = make(chan *cdp.Message)
+	h.res = make(map[int64]chan interface{})
+	h.pageq = make(chan interface{})
+	h.domWaitGroup = new(sync.WaitGroup)
+	h.term = make(chan struct{})
+
+	go h.run(ctxt)
+
+	return h
+}
+
+// result returns the result received on ch, waiting for ch to be closed (ie,
+// for the command to be removed from the pending commands).
+func result(t *testing.T, ch <-chan interface{}) interface{} {
+	var v interface{}
+	select {
+	case v = <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for result")
+	}
+
+	for range ch {
+	}
+
+	return v
+}
+
+// pending returns the number of commands waiting on a result.
+func pending(h *TargetHandler) int {
+	h.resrw.RLock()
+	defer h.resrw.RUnlock()
+
+	return len(h.res)
+}
+
+func TestExecuteLateResult(t *testing.T) {
+	conn := newTestTransport()
+	h := newTestHandler(t, conn)
+	defer h.Close()
+
+	ctxt, cancel := context.WithCancel(context.Background())
+	ch := h.Execute(ctxt, cdp.CommandPageEnable, nil)
+	id := conn.next(t)
+
+	cancel()
+	if v := result(t, ch); v != cdp.ErrContextDone {
+		t.Fatalf("expected %v, got: %v", cdp.ErrContextDone, v)
+	}
+	if n := pending(h); n != 0 {
+		t.Errorf("expected cancelled command to be removed, got %d pending", n)
+	}
+
+	// deliver the reply to the cancelled command, which must be ignored
+	conn.send(t, `{"id":`+strconv.FormatInt(id, 10)+`,"result":{}}`)
+
+	// the handler keeps routing results
+	ch = h.Execute(context.Background(), cdp.CommandPageEnable, nil)
+	id = conn.next(t)
+	conn.send(t, `{"id":`+strconv.FormatInt(id, 10)+`,"result":{"ok":true}}`)
+
+	v := result(t, ch)
+	buf, ok := v.(easyjson.RawMessage)
+	if !ok {
+		t.Fatalf("expected raw result, got: %#v", v)
+	}
+	if s := string(buf); s != `{"ok":true}` {
+		t.Errorf("expected result %s, got: %s", `{"ok":true}`, s)
+	}
+	if n := pending(h); n != 0 {
+		t.Errorf("expected no pending commands, got %d", n)
+	}
+}
+
+func TestExecuteTerminated(t *testing.T) {
+	tests := []struct {
+		ev  string
+		exp func(error) bool
+	}{
+		{`{"method":"Inspector.targetCrashed","params":{}}`, func(err error) bool {
+			return err == ErrTargetCrashed
+		}},
+		{`{"method":"Inspector.detached","params":{"reason":"replaced_with_devtools"}}`, func(err error) bool {
+			e, ok := err.(*TargetDetachedError)
+			return ok && e.Reason == inspector.DetachReasonReplacedWithDevtools
+		}},
+	}
+
+	for i, test := range tests {
+		conn := newTestTransport()
+		h := newTestHandler(t, conn)
+
+		// pending command and wait
+		ch := h.Execute(context.Background(), cdp.CommandPageEnable, nil)
+		conn.next(t)
+		waitErr := make(chan error, 1)
+		go func() {
+			_, err := h.WaitFrame(context.Background(), emptyFrameID)
+			waitErr <- err
+		}()
+
+		conn.send(t, test.ev)
+
+		if v := result(t, ch); !isErr(v, test.exp) {
+			t.Errorf("test %d expected pending command to fail with terminal error, got: %v", i, v)
+		}
+		select {
+		case err := <-waitErr:
+			if !test.exp(err) {
+				t.Errorf("test %d expected wait to fail with terminal error, got: %v", i, err)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("test %d timeout waiting for wait to fail", i)
+		}
+		if err := h.Err(); !test.exp(err) {
+			t.Errorf("test %d expected Err to return terminal error, got: %v", i, err)
+		}
+
+		// new commands fail fast
+		if v := result(t, h.Execute(context.Background(), cdp.CommandPageEnable, nil)); !isErr(v, test.exp) {
+			t.Errorf("test %d expected new command to fail with terminal error, got: %v", i, v)
+		}
+		if n := pending(h); n != 0 {
+			t.Errorf("test %d expected no pending commands, got %d", i, n)
+		}
+
+		h.Close()
+	}
+}
+
+// isErr determines if v is an error satisfying exp.
+func isErr(v interface{}, exp func(error) bool) bool {
+	err, ok := v.(error)
+	return ok && exp(err)
+}
@@ -3,18 +3,39 @@ package chromedp
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/knq/chromedp/cdp"
 	"github.com/knq/chromedp/cdp/dom"
 	"github.com/knq/chromedp/cdp/input"
+	rundom "github.com/knq/chromedp/cdp/runtime"
 )
 
 // Error types.
 var (
 	ErrInvalidDimensions = errors.New("invalid box dimensions")
+	ErrNotStable         = errors.New("not stable")
 )
 
+// InterceptedError is the error returned when an input action on a node would
+// be received by another element (ie, an overlay covering the node).
+type InterceptedError struct {
+	// NodeID is the target node.
+	NodeID cdp.NodeID
+
+	// X and Y are the location of the input action.
+	X, Y int64
+
+	// Description describes the intercepting element (ie, div#overlay).
+	Description string
+}
+
+// Error satisfies the error interface.
+func (e *InterceptedError) Error() string {
+	return fmt.Sprintf("action on node %d at (%d, %d) would be intercepted by %s", e.NodeID, e.X, e.Y, e.Description)
+}
+
 // MouseAction is a mouse action.
 func MouseAction(typ input.MouseType, x, y int64, opts ...MouseOption) Action {
 	f := input.DispatchMouseEvent(typ, x, y)
@@ -36,24 +57,80 @@ func MouseClickXY(x, y int64, opts ...MouseOption) Action {
 // MouseActionNode dispatches a mouse event at the center of a specified node.
 func MouseActionNode(n *cdp.Node, opts ...MouseOption) Action {
 	return ActionFunc(func(ctxt context.Context, h cdp.FrameHandler) error {
-		box, err := dom.GetBoxModel(n.NodeID).Do(ctxt, h)
+		x, y, err := nodeCenter(ctxt, h, n)
 		if err != nil {
 			return err
 		}
 
-		c := len(box.Content)
-		if c%2 != 0 {
-			return ErrInvalidDimensions
-		}
+		return MouseClickXY(x, y, opts...).Do(ctxt, h)
+	})
+}
 
-		var x, y int64
-		for i := 0; i < c; i += 2 {
-			x += int64(box.Content[i])
-			y += int64(box.Content[i+1])
-		}
+// nodeCenter returns the center of the node's content box.
+func nodeCenter(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) (int64, int64, error) {
+	box, err := dom.GetBoxModel(n.NodeID).Do(ctxt, h)
+	if err != nil {
+		return 0, 0, err
+	}
 
-		return MouseClickXY(x/int64(c/2), y/int64(c/2), opts...).Do(ctxt, h)
-	})
+	c := len(box.Content)
+	if c == 0 || c%2 != 0 {
+		return 0, 0, ErrInvalidDimensions
+	}
+
+	var x, y int64
+	for i := 0; i < c; i += 2 {
+		x += int64(box.Content[i])
+		y += int64(box.Content[i+1])
+	}
+
+	return x / int64(c/2), y / int64(c/2), nil
+}
+
+// checkActionable checks that an input action at the center of the node
+// would be received by the node, after scrolling the node into view. Returns
+// ErrDisabled or ErrNotStable when the node is disabled or is still moving
+// (ie, animating), and an *InterceptedError when another element would
+// receive the action.
+func checkActionable(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
+	if hasAttribute(n, "disabled") {
+		return ErrDisabled
+	}
+
+	// scroll into view, and check the bounding box did not move between two
+	// animation frames
+	var stable bool
+	err := callValue(ctxt, h, n, scrollStableJS, &stable)
+	if err != nil {
+		return err
+	}
+	if !stable {
+		return ErrNotStable
+	}
+
+	x, y, err := nodeCenter(ctxt, h, n)
+	if err != nil {
+		return err
+	}
+
+	id, contains, err := hitTest(ctxt, h, n, x, y)
+	if err != nil || contains {
+		return err
+	}
+
+	desc := fmt.Sprintf("node %d", id)
+	obj, err := dom.ResolveNode(id).Do(ctxt, h)
+	if err == nil {
+		desc = obj.Description
+		rundom.ReleaseObject(obj.ObjectID).Do(ctxt, h)
+	}
+
+	return &InterceptedError{
+		NodeID:      n.NodeID,
+		X:           x,
+		Y:           y,
+		Description: desc,
+	}
 }
 
 // MouseOption is a mouse action option.
@@ -92,8 +169,9 @@ func ClickCount(n int) MouseOption {
 
 // KeyAction contains information about a key action.
 type KeyAction struct {
-	v    string
-	opts []KeyOption
+	v     string
+	delay time.Duration
+	opts  []KeyOption
 }
 
 // KeyCode are known system key codes.
@@ -166,19 +244,29 @@ func (ka *KeyAction) Do(ctxt context.Context, h cdp.FrameHandler) error {
 			return err
 		}
 
-		// FIXME
-		time.Sleep(100 * time.Millisecond)
+		select {
+		case <-time.After(ka.delay):
+		case <-ctxt.Done():
+			return ctxt.Err()
+		}
 	}
 
 	return nil
 }
 
-// KeyActionNode dispatches a key event on a node.
+// KeyActionNode dispatches a key event on a node, waiting DefaultKeyDelay
+// after each typed key.
 func KeyActionNode(n *cdp.Node, v string, opts ...KeyOption) Action {
+	return KeyActionNodeDelay(n, v, DefaultKeyDelay, opts...)
+}
+
+// KeyActionNodeDelay dispatches a key event on a node, waiting delay after
+// each typed key.
+func KeyActionNodeDelay(n *cdp.Node, v string, delay time.Duration, opts ...KeyOption) Action {
 	return Tasks{
 		dom.Focus(n.NodeID),
 		MouseActionNode(n),
-		&KeyAction{v, opts},
+		&KeyAction{v, delay, opts},
 	}
 }
 
@@ -195,3 +283,30 @@ func KeyModifiers(modifiers ...input.Modifier) KeyOption {
 		return p
 	}
 }
+
+const (
+	// scrollStableJS scrolls this into view, resolving to true if its
+	// bounding box does not change between two animation frames (or, as
+	// animation frames are not run for hidden pages, between two timer
+	// ticks when the page is not visible).
+	scrollStableJS = `function() {
+		var el = this.nodeType === Node.ELEMENT_NODE ? this : this.parentElement;
+		if (el.scrollIntoViewIfNeeded) {
+			el.scrollIntoViewIfNeeded(true);
+		} else {
+			el.scrollIntoView({block: 'center', inline: 'center'});
+		}
+		var frame = document.visibilityState === 'visible' ? requestAnimationFrame : function(f) {
+			setTimeout(f, 16);
+		};
+		return new Promise(function(resolve) {
+			var a = el.getBoundingClientRect();
+			frame(function() {
+				frame(function() {
+					var b = el.getBoundingClientRect();
+					resolve(a.left === b.left && a.top === b.top && a.width === b.width && a.height === b.height);
+				});
+			});
+		});
+	}`
+)
@@ -0,0 +1,270 @@
+package chromedp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/knq/chromedp/cdp"
+	"github.com/knq/chromedp/cdp/dom"
+	rundom "github.com/knq/chromedp/cdp/runtime"
+)
+
+// objectGroupID is the last used remote object group identifier.
+var objectGroupID int64
+
+// newObjectGroup returns a unique remote object group name, so that the
+// remote objects retrieved by a call can be released together.
+func newObjectGroup() string {
+	return "chromedp-" + strconv.FormatInt(atomic.AddInt64(&objectGroupID, 1), 10)
+}
+
+// callArguments JSON encodes args as call arguments.
+func callArguments(args ...interface{}) ([]*rundom.CallArgument, error) {
+	params := make([]*rundom.CallArgument, len(args))
+	for i, arg := range args {
+		buf, err := json.Marshal(arg)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode argument %d, got: %v", i, err)
+		}
+
+		params[i] = &rundom.CallArgument{Value: buf}
+	}
+
+	return params, nil
+}
+
+// callNodes calls the JavaScript function fn with n as this and the JSON
+// encoded args, returning the node IDs of the elements returned by fn (see
+// objectNodes).
+func callNodes(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node, fn string, args ...interface{}) ([]cdp.NodeID, error) {
+	params, err := callArguments(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	group := newObjectGroup()
+	defer rundom.ReleaseObjectGroup(group).Do(ctxt, h)
+
+	obj, err := dom.ResolveNode(n.NodeID).WithObjectGroup(group).Do(ctxt, h)
+	if err != nil {
+		return nil, err
+	}
+
+	res, exp, err := rundom.CallFunctionOn(obj.ObjectID, fn).WithArguments(params).Do(ctxt, h)
+	if err != nil {
+		return nil, err
+	}
+	if exp != nil {
+		return nil, newExceptionError(exp)
+	}
+
+	return objectNodes(ctxt, h, res)
+}
+
+// evalNodes evaluates the JavaScript expression expr, returning the node IDs
+// of the elements it evaluates to (see objectNodes).
+func evalNodes(ctxt context.Context, h cdp.FrameHandler, expr string) ([]cdp.NodeID, error) {
+	group := newObjectGroup()
+	defer rundom.ReleaseObjectGroup(group).Do(ctxt, h)
+
+	res, exp, err := rundom.Evaluate(expr).WithObjectGroup(group).Do(ctxt, h)
+	if err != nil {
+		return nil, err
+	}
+	if exp != nil {
+		return nil, newExceptionError(exp)
+	}
+
+	return objectNodes(ctxt, h, res)
+}
+
+// objectNodes returns the node IDs for the remote object obj, which can be a
+// single node, or an array-like object (ie, a NodeList, HTMLCollection or
+// array) of nodes. Null and undefined are treated as no nodes.
+//
+// Any remote objects retrieved are placed in the same object group as obj.
+func objectNodes(ctxt context.Context, h cdp.FrameHandler, obj *rundom.RemoteObject) ([]cdp.NodeID, error) {
+	if obj.Type == rundom.TypeUndefined || obj.Subtype == rundom.SubtypeNull {
+		return nil, nil
+	}
+	if obj.ObjectID == "" {
+		return nil, fmt.Errorf("expected node, NodeList or array, got: %s", obj.Type)
+	}
+
+	// convert to array
+	res, exp, err := rundom.CallFunctionOn(obj.ObjectID, toArrayJS).Do(ctxt, h)
+	if err != nil {
+		return nil, err
+	}
+	if exp != nil {
+		return nil, fmt.Errorf("got exception converting to array: %s", exp.Text)
+	}
+	if res.Subtype != rundom.SubtypeArray || res.ObjectID == "" {
+		return nil, fmt.Errorf("expected node, NodeList or array, got: %s", obj.Type)
+	}
+
+	props, _, exp, err := rundom.GetProperties(res.ObjectID).WithOwnProperties(true).Do(ctxt, h)
+	if err != nil {
+		return nil, err
+	}
+	if exp != nil {
+		return nil, fmt.Errorf("got exception retrieving array elements: %s", exp.Text)
+	}
+
+	// collect nodes in index order
+	var idx []int
+	objs := make(map[int]rundom.RemoteObjectID)
+	for _, p := range props {
+		i, err := strconv.Atoi(p.Name)
+		if err != nil || p.Value == nil || p.Value.Subtype != rundom.SubtypeNode {
+			continue
+		}
+		idx = append(idx, i)
+		objs[i] = p.Value.ObjectID
+	}
+	sort.Ints(idx)
+
+	ids := make([]cdp.NodeID, len(idx))
+	for j, i := range idx {
+		ids[j], err = dom.RequestNode(objs[i]).Do(ctxt, h)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ids, nil
+}
+
+// CallFunctionOn is an action that calls the JavaScript function declaration
+// fn with the node as this and args (JSON encoded) as its arguments, decoding
+// the JSON encoded result into res (when not nil). A returned promise is
+// awaited before its result is decoded.
+//
+// Values are passed as arguments, and never interpolated into the script, so
+// any value is safe to pass. For example:
+//
+//	CallFunctionOn(n, `function(v) { this.value = v; }`, nil, value)
+func CallFunctionOn(n *cdp.Node, fn string, res interface{}, args ...interface{}) Action {
+	return ActionFunc(func(ctxt context.Context, h cdp.FrameHandler) error {
+		return callValue(ctxt, h, n, fn, res, args...)
+	})
+}
+
+// callValue calls the JavaScript function fn with n as this and the JSON
+// encoded args, awaiting the result if it is a promise, and decoding the JSON
+// encoded result into res (when not nil).
+func callValue(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node, fn string, res interface{}, args ...interface{}) error {
+	params, err := callArguments(args...)
+	if err != nil {
+		return err
+	}
+
+	group := newObjectGroup()
+	defer rundom.ReleaseObjectGroup(group).Do(ctxt, h)
+
+	obj, err := dom.ResolveNode(n.NodeID).WithObjectGroup(group).Do(ctxt, h)
+	if err != nil {
+		return err
+	}
+
+	v, exp, err := rundom.CallFunctionOn(obj.ObjectID, fn).
+		WithArguments(params).
+		WithReturnByValue(true).
+		WithAwaitPromise(true).
+		Do(ctxt, h)
+	if err != nil {
+		return err
+	}
+	if exp != nil {
+		return newExceptionError(exp)
+	}
+
+	if res == nil || len(v.Value) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(v.Value, res)
+}
+
+// hitTest returns the id of the node at the location, and whether it is n or
+// a descendant of n (ie, n would receive an input action at the location).
+func hitTest(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node, x, y int64) (cdp.NodeID, bool, error) {
+	id, err := dom.GetNodeForLocation(x, y).Do(ctxt, h)
+	if err != nil {
+		return 0, false, err
+	}
+
+	contains, err := containsNode(ctxt, h, n, id)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return id, contains, nil
+}
+
+// containsNode determines if the node with the specified id is n, or a
+// descendant of n (including through shadow roots).
+func containsNode(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node, id cdp.NodeID) (bool, error) {
+	if n.NodeID == id {
+		return true, nil
+	}
+
+	group := newObjectGroup()
+	defer rundom.ReleaseObjectGroup(group).Do(ctxt, h)
+
+	obj, err := dom.ResolveNode(n.NodeID).WithObjectGroup(group).Do(ctxt, h)
+	if err != nil {
+		return false, err
+	}
+	other, err := dom.ResolveNode(id).WithObjectGroup(group).Do(ctxt, h)
+	if err != nil {
+		return false, err
+	}
+
+	v, exp, err := rundom.CallFunctionOn(obj.ObjectID, containsJS).
+		WithArguments([]*rundom.CallArgument{{ObjectID: other.ObjectID}}).
+		WithReturnByValue(true).
+		Do(ctxt, h)
+	if err != nil {
+		return false, err
+	}
+	if exp != nil {
+		return false, newExceptionError(exp)
+	}
+
+	var contains bool
+	err = json.Unmarshal(v.Value, &contains)
+	if err != nil {
+		return false, err
+	}
+
+	return contains, nil
+}
+
+const (
+	// containsJS determines if node is this, or a descendant of this,
+	// including through shadow roots.
+	containsJS = `function(node) {
+		for (var n = node; n; n = n.parentNode || n.host) {
+			if (n === this) {
+				return true;
+			}
+		}
+		return false;
+	}`
+
+	// toArrayJS converts this to an array of nodes.
+	toArrayJS = `function() {
+		if (this instanceof Node) {
+			return [this];
+		}
+		if (typeof this.length === 'number') {
+			return Array.prototype.slice.call(this);
+		}
+		throw new TypeError('expected node, NodeList or array');
+	}`
+)
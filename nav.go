@@ -3,12 +3,9 @@ package chromedp
 import (
 	"context"
 	"errors"
-	"fmt"
-	"log"
 
 	"github.com/knq/chromedp/cdp"
 	"github.com/knq/chromedp/cdp/page"
-	rundom "github.com/knq/chromedp/cdp/runtime"
 )
 
 // Navigate navigates the current frame.
@@ -121,44 +118,11 @@ func Stop() Action {
 	return page.StopLoading()
 }
 
-// Evaluate evaluates a script.
-func Evaluate(expression string, res **rundom.RemoteObject) Action {
-	if res == nil {
-		panic("res cannot be nil")
-	}
-
-	return ActionFunc(func(ctxt context.Context, h cdp.FrameHandler) error {
-		v, exp, err := rundom.Evaluate(expression).Do(ctxt, h)
-		if err != nil {
-			return err
-		}
-		if exp != nil {
-			log.Printf(">>> GOT EXECPTION: %v", exp)
-		}
-		*res = v
-		return nil
-	})
-}
-
 // Location retrieves the URL location.
 func Location(urlstr *string) Action {
 	if urlstr == nil {
 		panic("urlstr cannot be nil")
 	}
-	return ActionFunc(func(ctxt context.Context, h cdp.FrameHandler) error {
-		res, exp, err := rundom.Evaluate(`location.toString()`).Do(ctxt, h)
-		if err != nil {
-			return err
-		}
-		if exp != nil {
-			return fmt.Errorf("got exception evaluating script: %#v", exp)
-		}
-		if res.Type != rundom.TypeString || len(res.Value) < 2 {
-			return fmt.Errorf("expected string of at least length 2, got %s length %d", res.Subtype, len(res.Value))
-		}
 
-		*urlstr = string(res.Value[1 : len(res.Value)-1])
-
-		return nil
-	})
+	return Evaluate(`location.toString()`, urlstr)
 }
@@ -3,7 +3,6 @@ package chromedp
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"image"
@@ -16,7 +15,6 @@ import (
 	"github.com/knq/chromedp/cdp/dom"
 	"github.com/knq/chromedp/cdp/input"
 	"github.com/knq/chromedp/cdp/page"
-	rundom "github.com/knq/chromedp/cdp/runtime"
 )
 
 var (
@@ -133,24 +131,7 @@ func Value(sel interface{}, value *string, opts ...QueryOption) Action {
 			return fmt.Errorf("selector `%s` did not return any nodes", sel)
 		}
 
-		p := rundom.Evaluate(fmt.Sprintf(valueJS, nodes[0].XPath()))
-		p.IncludeCommandLineAPI = true
-		p.ObjectGroup = "console"
-
-		res, exp, err := p.Do(ctxt, h)
-		if err != nil {
-			return err
-		}
-		if exp != nil {
-			return fmt.Errorf("got exception evaluating script: %#v", exp)
-		}
-		if res.Type != rundom.TypeString || len(res.Value) < 2 {
-			return fmt.Errorf("expected string of at least length 2, got %s length %d", res.Subtype, len(res.Value))
-		}
-
-		*value = string(res.Value[1 : len(res.Value)-1])
-
-		return nil
+		return CallFunctionOn(nodes[0], valueJS, value).Do(ctxt, h)
 	}, opts...)
 }
 
@@ -161,22 +142,7 @@ func SetValue(sel interface{}, value string, opts ...QueryOption) Action {
 			return fmt.Errorf("selector `%s` did not return any nodes", sel)
 		}
 
-		p := rundom.Evaluate(fmt.Sprintf(setValueJS, nodes[0].XPath(), value))
-		p.IncludeCommandLineAPI = true
-		p.ObjectGroup = "console"
-
-		res, exp, err := p.Do(ctxt, h)
-		if err != nil {
-			return err
-		}
-		if exp != nil {
-			return fmt.Errorf("got exception evaluating script: %#v", exp)
-		}
-		if res.Type != rundom.TypeString || len(res.Value) < 2 {
-			return fmt.Errorf("expected string of at least length 2, got %s length %d", res.Subtype, len(res.Value))
-		}
-
-		return nil
+		return CallFunctionOn(nodes[0], setValueJS, nil, value).Do(ctxt, h)
 	}, opts...)
 }
 
@@ -190,24 +156,7 @@ func Text(sel interface{}, text *string, opts ...QueryOption) Action {
 			return fmt.Errorf("selector `%s` did not return any nodes", sel)
 		}
 
-		p := rundom.Evaluate(fmt.Sprintf(textJS, nodes[0].XPath()))
-		p.IncludeCommandLineAPI = true
-		p.ObjectGroup = "console"
-
-		res, exp, err := p.Do(ctxt, h)
-		if err != nil {
-			return err
-		}
-		if exp != nil {
-			return fmt.Errorf("got exception evaluating script: %#v", exp)
-		}
-		if res.Type != rundom.TypeString || len(res.Value) < 2 {
-			return fmt.Errorf("expected string of at least length 2, got %s length %d", res.Subtype, len(res.Value))
-		}
-
-		*text = string(res.Value[1 : len(res.Value)-1])
-
-		return nil
+		return CallFunctionOn(nodes[0], textJS, text).Do(ctxt, h)
 	}, opts...)
 }
 
@@ -339,13 +288,19 @@ func Hover(sel interface{}, opts ...QueryOption) Action {
 }
 
 // SendKeys sends keys to the first element returned by selector.
+//
+// The typing delay and key action options can be set with the KeyDelay and
+// KeyOptions query options.
 func SendKeys(sel interface{}, v string, opts ...QueryOption) Action {
-	return QueryAfter(sel, func(ctxt context.Context, h cdp.FrameHandler, nodes ...*cdp.Node) error {
+	s := Query(sel, append(opts, ElementVisible)...).(*Selector)
+	s.after = func(ctxt context.Context, h cdp.FrameHandler, nodes ...*cdp.Node) error {
 		if len(nodes) < 1 {
 			return fmt.Errorf("selector `%s` did not return any nodes", sel)
 		}
-		return KeyActionNode(nodes[0], v).Do(ctxt, h)
-	}, append(opts, ElementVisible)...)
+		return KeyActionNodeDelay(nodes[0], v, s.keyDelay, s.keyOpts...).Do(ctxt, h)
+	}
+
+	return s
 }
 
 // Screenshot takes a screenshot of the first element matching the selector.
@@ -373,23 +328,14 @@ func Screenshot(sel interface{}, picbuf *[]byte, opts ...QueryOption) Action {
 		}
 
 		// evaluate scroll script
-		res, exp, err := rundom.Evaluate(fmt.Sprintf(scrollJS, int64(box.Margin[0]), int64(box.Margin[1]))).Do(ctxt, h)
-		if err != nil {
-			return err
-		}
-		if exp != nil {
-			return fmt.Errorf("got exception evaluating script: %#v", exp)
-		}
-		if res.Type != rundom.TypeString || len(res.Value) < 2 {
-			return fmt.Errorf("expected string of at least length 2, got %s length %d", res.Subtype, len(res.Value))
-		}
-
-		// parse response
 		var scroll []int
-		err = json.Unmarshal([]byte(res.Value[1:len(res.Value)-1]), &scroll)
+		err = Evaluate(fmt.Sprintf(scrollJS, int64(box.Margin[0]), int64(box.Margin[1])), &scroll).Do(ctxt, h)
 		if err != nil {
 			return err
 		}
+		if len(scroll) != 2 {
+			return fmt.Errorf("expected scroll position, got: %v", scroll)
+		}
 
 		// take page screenshot
 		buf, err := page.CaptureScreenshot().Do(ctxt, h)
@@ -422,62 +368,50 @@ func Screenshot(sel interface{}, picbuf *[]byte, opts ...QueryOption) Action {
 	}, append(opts, ElementVisible)...)
 }
 
-// Submit is an action that submits whatever form the first element belongs to.
+// Submit is an action that submits the first element when it is a form, or
+// otherwise the form it belongs to. An ExceptionError is returned when the
+// element is not in a form.
 func Submit(sel interface{}, opts ...QueryOption) Action {
 	return QueryAfter(sel, func(ctxt context.Context, h cdp.FrameHandler, nodes ...*cdp.Node) error {
 		if len(nodes) < 1 {
 			return fmt.Errorf("selector `%s` did not return any nodes", sel)
 		}
 
-		p := rundom.Evaluate(fmt.Sprintf(submitJS, nodes[0].XPath()))
-		p.IncludeCommandLineAPI = true
-		p.ObjectGroup = "console"
-
-		res, exp, err := p.Do(ctxt, h)
-		if err != nil {
-			return err
-		}
-		if exp != nil {
-			return fmt.Errorf("got exception evaluating script: %#v", exp)
-		}
-		if res.Type != rundom.TypeString || len(res.Value) < 2 {
-			return fmt.Errorf("expected string of at least length 2, got %s length %d", res.Subtype, len(res.Value))
-		}
-
-		return nil
+		return CallFunctionOn(nodes[0], submitJS, nil).Do(ctxt, h)
 	}, opts...)
 }
 
 const (
-	textJS = `(function(a) {
+	textJS = `function() {
 		var s = '';
-		for (var i = 0; i < a.length; i++) {
-			if (a[i].offsetParent !== null) {
-				s += a[i].textContent;
+		for (var i = 0; i < this.childNodes.length; i++) {
+			if (this.childNodes[i].offsetParent !== null) {
+				s += this.childNodes[i].textContent;
 			}
 		}
 		return s;
-	})($x("%s/node()"))`
+	}`
 
 	scrollJS = `(function(x, y) {
 		window.scrollTo(x, y);
-		return '['+window.scrollX+','+window.scrollY+']';
+		return [window.scrollX, window.scrollY];
 	})(%d, %d)`
 
-	submitJS = `(function(a) {
-		if (a[0].form !== null) {
-			return "" + a[0].form.submit();
+	submitJS = `function() {
+		var f = this.tagName === 'FORM' ? this : this.form;
+		if (!f) {
+			throw new Error('element is not a form and does not belong to a form');
 		}
-		return 'false';
-	})($x('%s'))`
+		f.submit();
+	}`
 
-	valueJS = `(function(a) {
-		return a[0].value;
-	})($x('%s'))`
+	valueJS = `function() {
+		return this.value;
+	}`
 
-	setValueJS = `(function(a, val) {
-		return a[0].value = val;
-	})($x('%s'), '%s')`
+	setValueJS = `function(val) {
+		this.value = val;
+	}`
 )
 
 /*
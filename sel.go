@@ -9,45 +9,66 @@ import (
 	"time"
 
 	"github.com/knq/chromedp/cdp"
-	"github.com/knq/chromedp/cdp/css"
 	"github.com/knq/chromedp/cdp/dom"
 )
 
-/*
-
-TODO: selector 'by' type, as below:
-classname
-linktext
-name
-partiallinktext
-tagname
-
-*/
-
 // Error types.
 var (
-	ErrNoResults   = errors.New("no results")
-	ErrNotVisible  = errors.New("not visible")
-	ErrVisible     = errors.New("visible")
-	ErrDisabled    = errors.New("disabled")
-	ErrNotSelected = errors.New("not selected")
+	ErrNoResults       = errors.New("no results")
+	ErrInvalidated     = errors.New("node invalidated")
+	ErrInvalidSelector = errors.New("invalid selector")
+	ErrNotVisible      = errors.New("not visible")
+	ErrVisible         = errors.New("visible")
+	ErrDisabled        = errors.New("disabled")
+	ErrNotSelected     = errors.New("not selected")
+	ErrOccluded        = errors.New("occluded")
 )
 
 // Selector holds information pertaining to an element query select action.
 type Selector struct {
 	sel   interface{}
 	exp   int
+	max   int
 	by    func(context.Context, cdp.FrameHandler, *cdp.Node) ([]cdp.NodeID, error)
 	wait  func(context.Context, cdp.FrameHandler, *cdp.Node, ...cdp.NodeID) ([]*cdp.Node, error)
 	after func(context.Context, cdp.FrameHandler, ...*cdp.Node) error
+
+	// timeout and interval override the handler's query timeout and poll
+	// interval.
+	timeout, interval time.Duration
+
+	// from is the node to search from, instead of the document root.
+	from *cdp.Node
+
+	// frame retrieves the frame document to search, instead of the document
+	// root.
+	frame func(context.Context, cdp.FrameHandler, *cdp.Node) (*cdp.Node, error)
+
+	// axName is the accessible name to match with ByRole.
+	axName *string
+
+	// normalize and tag are the whitespace normalization and tag name
+	// filter for the text selectors.
+	normalize bool
+	tag       string
+
+	// actionable toggles the actionability checks prior to after.
+	actionable bool
+
+	// keyDelay and keyOpts are the typing delay and key action options for
+	// SendKeys.
+	keyDelay time.Duration
+	keyOpts  []KeyOption
 }
 
 // Query is an action to query for document nodes match the specified sel and
 // the supplied query options.
 func Query(sel interface{}, opts ...QueryOption) Action {
 	s := &Selector{
-		sel: sel,
-		exp: 1,
+		sel:      sel,
+		exp:      1,
+		max:      -1,
+		keyDelay: DefaultKeyDelay,
 	}
 
 	// apply options
@@ -68,13 +89,28 @@ func Query(sel interface{}, opts ...QueryOption) Action {
 
 // Do satisfies the Action interface.
 func (s *Selector) Do(ctxt context.Context, h cdp.FrameHandler) error {
-	// TODO: fix this
-	ctxt, cancel := context.WithTimeout(ctxt, 100*time.Second)
-	defer cancel()
+	timeout, interval := s.timeout, s.interval
+	if th, ok := h.(*TargetHandler); ok {
+		if timeout == 0 {
+			timeout = th.queryTimeout
+		}
+		if interval == 0 {
+			interval = th.pollInterval
+		}
+	}
+	if interval <= 0 {
+		interval = DefaultCheckDuration
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctxt, cancel = context.WithTimeout(ctxt, timeout)
+		defer cancel()
+	}
 
 	var err error
 	select {
-	case err = <-s.run(ctxt, h):
+	case err = <-s.run(ctxt, h, interval):
 	case <-ctxt.Done():
 		err = ctxt.Err()
 	}
@@ -85,40 +121,46 @@ func (s *Selector) Do(ctxt context.Context, h cdp.FrameHandler) error {
 // run runs the selector action, starting over if the original returned nodes
 // are invalidated prior to finishing the selector's by, wait, check, and after
 // funcs.
-func (s *Selector) run(ctxt context.Context, h cdp.FrameHandler) chan error {
-	ch := make(chan error)
+//
+// Attempts are retried when a DOM event is received from the handler, or
+// after interval for changes not reported by DOM events (ie, style and layout
+// changes).
+func (s *Selector) run(ctxt context.Context, h cdp.FrameHandler, interval time.Duration) chan error {
+	ch := make(chan error, 1)
 
 	go func() {
 		defer close(ch)
 
+		// remove the listener once done
+		lctxt, cancel := context.WithCancel(ctxt)
+		defer cancel()
+
+		events := h.Listen(lctxt, dom.EventTypes...)
 		for {
 			root, err := h.GetRoot(ctxt)
 			if err != nil {
-				continue
+				ch <- err
+				return
+			}
+
+			done, err := s.attempt(ctxt, h, root)
+			if done {
+				if err != nil {
+					ch <- err
+				}
+				return
 			}
 
 			select {
-			default:
-				ids, err := s.by(ctxt, h, root)
-				if err == nil && len(ids) >= s.exp {
-					nodes, err := s.wait(ctxt, h, root, ids...)
-					if err == nil {
-						if s.after == nil {
-							return
-						}
-
-						err = s.after(ctxt, h, nodes...)
-						if err != nil {
-							ch <- err
-						}
-						return
-					}
+			case _, ok := <-events:
+				if !ok {
+					events = nil
 				}
+				drain(events)
 
-				time.Sleep(DefaultCheckDuration)
+			case <-time.After(interval):
 
 			case <-root.Invalidated:
-				continue
 
 			case <-ctxt.Done():
 				ch <- ctxt.Err()
@@ -130,6 +172,122 @@ func (s *Selector) run(ctxt context.Context, h cdp.FrameHandler) chan error {
 	return ch
 }
 
+// attempt makes a single attempt at running the selector's by, wait, and
+// after funcs against root, returning true when the selector has finished.
+//
+// The attempt is cancelled, and retried, if root is invalidated before the
+// after func is run. Once the after func has run, its result is final, even
+// when it invalidated root (ie, a Click that navigates).
+func (s *Selector) attempt(ctxt context.Context, h cdp.FrameHandler, root *cdp.Node) (bool, error) {
+	ctxt, cancel := context.WithCancel(ctxt)
+	defer cancel()
+	go func() {
+		select {
+		case <-root.Invalidated:
+			cancel()
+		case <-ctxt.Done():
+		}
+	}()
+
+	n := root
+	if s.from != nil {
+		// the scope will never match when its document has been replaced
+		if invalidated(s.from) {
+			return true, ErrInvalidated
+		}
+		n = s.from
+	}
+
+	if s.frame != nil {
+		doc, err := s.frame(ctxt, h, n)
+		if err != nil || doc == nil {
+			return false, nil
+		}
+		n = doc
+	}
+
+	ids, err := s.by(ctxt, h, n)
+	if _, ok := err.(*ExceptionError); ok || err == ErrInvalidSelector {
+		// script errors (ie, a syntax error) and invalid selectors will not
+		// resolve on retry
+		return true, err
+	}
+	if err == nil {
+		ids, err = descendants(ctxt, h, n, ids)
+	}
+	if err != nil || len(ids) < s.exp || (s.max >= 0 && len(ids) > s.max) {
+		return false, nil
+	}
+
+	nodes, err := s.wait(ctxt, h, n, ids...)
+	if err != nil || invalidated(root) {
+		return false, nil
+	}
+
+	if s.after == nil {
+		return true, nil
+	}
+
+	if s.actionable && len(nodes) > 0 {
+		switch err := checkActionable(ctxt, h, nodes[0]); err {
+		case nil:
+		case ErrDisabled, ErrNotStable:
+			return false, nil
+		default:
+			return !invalidated(root), err
+		}
+	}
+
+	return true, s.after(ctxt, h, nodes...)
+}
+
+// invalidated determines if n has been invalidated (ie, its document was
+// replaced).
+func invalidated(n *cdp.Node) bool {
+	select {
+	case <-n.Invalidated:
+		return true
+	default:
+		return false
+	}
+}
+
+// descendants filters ids to the descendants of n, for by funcs that search
+// the whole page (ie, BySearch and ByJSPath), when n is not the top level
+// document (ie, when searching from a node, or inside a frame).
+func descendants(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node, ids []cdp.NodeID) ([]cdp.NodeID, error) {
+	f, err := h.WaitFrame(ctxt, emptyFrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	f.RLock()
+	top := f.Root == n
+	f.RUnlock()
+	if top {
+		return ids, nil
+	}
+
+	var res []cdp.NodeID
+	for _, id := range ids {
+		c, err := h.WaitNode(ctxt, f, id)
+		if err != nil {
+			return nil, err
+		}
+
+		f.RLock()
+		for p := c.Parent; p != nil; p = p.Parent {
+			if p.NodeID == n.NodeID {
+				res = append(res, id)
+				break
+			}
+		}
+		f.RUnlock()
+	}
+
+	return res, nil
+}
+
 // selAsString forces sel into a string.
 func (s *Selector) selAsString() string {
 	if sel, ok := s.sel.(string); ok {
@@ -192,10 +350,216 @@ func ByQueryAll(s *Selector) {
 
 // ByID is a query option to select a single element by their CSS #id.
 func ByID(s *Selector) {
-	s.sel = "#" + strings.TrimPrefix(s.selAsString(), "#")
+	s.sel = "#" + cssIdent(strings.TrimPrefix(s.selAsString(), "#"))
+	ByQuery(s)
+}
+
+// ByClassName is a query option to select a single element by its class name.
+//
+// As with WebDriver, the class name cannot be a compound class name (ie,
+// contain whitespace), and the query fails with ErrInvalidSelector when it is
+// empty or compound.
+func ByClassName(s *Selector) {
+	ByFunc(func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) ([]cdp.NodeID, error) {
+		class := s.selAsString()
+		if class == "" || strings.ContainsAny(class, " \t\n\r\f") {
+			return nil, ErrInvalidSelector
+		}
+
+		nodeID, err := dom.QuerySelector(n.NodeID, "."+cssIdent(class)).Do(ctxt, h)
+		if err != nil {
+			return nil, err
+		}
+
+		if nodeID == emptyNodeID {
+			return []cdp.NodeID{}, nil
+		}
+
+		return []cdp.NodeID{nodeID}, nil
+	})(s)
+}
+
+// ByTagName is a query option to select a single element by its tag name.
+func ByTagName(s *Selector) {
+	s.sel = cssIdent(s.selAsString())
+	ByQuery(s)
+}
+
+// ByName is a query option to select a single element by its name attribute.
+func ByName(s *Selector) {
+	s.sel = "[name=" + cssString(s.selAsString()) + "]"
 	ByQuery(s)
 }
 
+// ByLinkText is a query option to select a single anchor element whose
+// rendered text (with leading and trailing whitespace removed) is equal to
+// the selector.
+func ByLinkText(s *Selector) {
+	ByFunc(func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) ([]cdp.NodeID, error) {
+		return callNodes(ctxt, h, n, linkTextJS, s.selAsString(), false)
+	})(s)
+}
+
+// ByPartialLinkText is a query option to select a single anchor element whose
+// rendered text contains the selector.
+func ByPartialLinkText(s *Selector) {
+	ByFunc(func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) ([]cdp.NodeID, error) {
+		return callNodes(ctxt, h, n, linkTextJS, s.selAsString(), true)
+	})(s)
+}
+
+// ByQueryShadow is a query option to select a single element using CSS
+// selectors joined by the shadow-piercing combinator `>>>`, where each
+// selector after a `>>>` is matched inside the shadow roots of the elements
+// matched by the selector before it.
+//
+// For example, `x-app >>> x-panel >>> button` matches the first button in the
+// shadow root of an x-panel that is itself in the shadow root of an x-app.
+// A leading `>>>` matches inside the shadow roots of the node being searched.
+func ByQueryShadow(s *Selector) {
+	ByFunc(func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) ([]cdp.NodeID, error) {
+		ids, err := queryShadow(ctxt, h, n, s.selAsString())
+		if err != nil || len(ids) == 0 {
+			return ids, err
+		}
+
+		return ids[:1], nil
+	})(s)
+}
+
+// ByQueryShadowAll is a query option to select all elements using CSS
+// selectors joined by the shadow-piercing combinator `>>>` (see
+// ByQueryShadow).
+func ByQueryShadowAll(s *Selector) {
+	ByFunc(func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) ([]cdp.NodeID, error) {
+		return queryShadow(ctxt, h, n, s.selAsString())
+	})(s)
+}
+
+// queryShadow matches the `>>>` joined selectors in sel, starting from n,
+// using the mirrored shadow roots of the matched hosts.
+func queryShadow(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node, sel string) ([]cdp.NodeID, error) {
+	parts := strings.Split(sel, ">>>")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	ids := []cdp.NodeID{n.NodeID}
+	if parts[0] != "" {
+		var err error
+		ids, err = dom.QuerySelectorAll(n.NodeID, parts[0]).Do(ctxt, h)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := h.WaitFrame(ctxt, emptyFrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, part := range parts[1:] {
+		var next []cdp.NodeID
+		for _, id := range ids {
+			host, err := h.WaitNode(ctxt, f, id)
+			if err != nil {
+				return nil, err
+			}
+
+			f.RLock()
+			roots := make([]*cdp.Node, len(host.ShadowRoots))
+			copy(roots, host.ShadowRoots)
+			f.RUnlock()
+
+			for _, root := range roots {
+				res, err := dom.QuerySelectorAll(root.NodeID, part).Do(ctxt, h)
+				if err != nil {
+					return nil, err
+				}
+				next = append(next, res...)
+			}
+		}
+		ids = next
+	}
+
+	return ids, nil
+}
+
+// ByText is a query option to select a single element whose visible text
+// (with leading and trailing whitespace removed) is equal to the selector.
+//
+// When several nested elements match, the innermost is selected. See
+// NormalizeSpace and TextTag for changing how elements are matched.
+func ByText(s *Selector) {
+	s.byText("exact")
+}
+
+// ByTextContains is a query option to select a single element whose visible
+// text contains the selector (see ByText).
+func ByTextContains(s *Selector) {
+	s.byText("contains")
+}
+
+// ByTextRegexp is a query option to select a single element whose visible
+// text matches the selector, as a JavaScript regular expression (see ByText).
+// The query fails with an *ExceptionError when the regular expression is
+// invalid.
+func ByTextRegexp(s *Selector) {
+	s.byText("regexp")
+}
+
+// byText sets the selector to match elements by their visible text using
+// mode.
+func (s *Selector) byText(mode string) {
+	ByFunc(func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) ([]cdp.NodeID, error) {
+		tag := "*"
+		if s.tag != "" {
+			tag = cssIdent(s.tag)
+		}
+
+		return callNodes(ctxt, h, n, textMatchJS, s.selAsString(), mode, s.normalize, tag)
+	})(s)
+}
+
+// NormalizeSpace is a query option for the text selectors to collapse runs of
+// whitespace in the element's text (and the selector) to a single space
+// before matching.
+func NormalizeSpace(s *Selector) {
+	s.normalize = true
+}
+
+// TextTag is a query option for the text selectors to only match elements
+// with the specified tag name (ie, "a" or "button").
+func TextTag(tag string) QueryOption {
+	return func(s *Selector) {
+		s.tag = tag
+	}
+}
+
+// ByJSPath is a query option to select elements by evaluating the selector
+// as a JavaScript expression (ie, `document.querySelector('x-app').shadowRoot`),
+// that evaluates to an element, NodeList, HTMLCollection, or array of
+// elements.
+func ByJSPath(s *Selector) {
+	ByFunc(func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) ([]cdp.NodeID, error) {
+		return evalNodes(ctxt, h, s.selAsString())
+	})(s)
+}
+
+// ByJSFunc is a query option to select elements by calling the selector as a
+// JavaScript function declaration, with the node being searched (ie, the
+// document) as this. The function can return an element, NodeList,
+// HTMLCollection, or array of elements.
+//
+// For example:
+//
+//	Query(`function() { return this.querySelectorAll('tr.selected'); }`, ByJSFunc)
+func ByJSFunc(s *Selector) {
+	ByFunc(func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) ([]cdp.NodeID, error) {
+		return callNodes(ctxt, h, n, s.selAsString())
+	})(s)
+}
+
 // BySearch is a query option via DOM.performSearch (works with both CSS and
 // XPath queries).
 func BySearch(s *Selector) {
@@ -300,133 +664,110 @@ func ElementReady(s *Selector) {
 
 // ElementVisible is a query option to wait until the element is visible.
 func ElementVisible(s *Selector) {
-	WaitFunc(s.waitReady(func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
-		var err error
-
-		// check node has box model
-		_, err = dom.GetBoxModel(n.NodeID).Do(ctxt, h)
-		if err != nil {
-			return err
-		}
-
-		// check if any of the parents are not visible ...
-		var hidden bool
-		for ; n.Parent != nil; n = n.Parent {
-			// get style
-			style, err := css.GetComputedStyleForNode(n.NodeID).Do(ctxt, h)
-			if err != nil {
-				return err
-			}
-
-			// check if hidden
-			for _, c := range style {
-				switch c.Name {
-				case "display":
-					//log.Printf("%d >>>> %s=%s", n.NodeID, c.Name, c.Value)
-					hidden = c.Value == "none"
-
-				case "visibility":
-					//log.Printf("%d >>>> %s=%s", n.NodeID, c.Name, c.Value)
-					hidden = c.Value != "visible"
-
-				case "hidden":
-					//log.Printf("%d >>>> %s=%s", n.NodeID, c.Name, c.Value)
-					hidden = true
-				}
-
-				if hidden {
-					return ErrNotVisible
-				}
-			}
-		}
-
-		return nil
-	}))(s)
+	WaitFunc(s.waitReady(Visible))(s)
 }
 
-// ElementNotVisible is a query option to wait until the element is visible.
+// ElementNotVisible is a query option to wait until the element is not
+// visible.
 func ElementNotVisible(s *Selector) {
-	WaitFunc(s.waitReady(func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
-		var err error
-
-		// check node has box model
-		_, err = dom.GetBoxModel(n.NodeID).Do(ctxt, h)
-		if err != nil {
-			return nil
-		}
+	WaitFunc(s.waitReady(NotVisible))(s)
+}
 
-		// check if any of the parents are not visible ...
-		var hidden bool
-		for ; n.Parent != nil; n = n.Parent {
-			// get style
-			style, err := css.GetComputedStyleForNode(n.NodeID).Do(ctxt, h)
-			if err != nil {
-				return err
-			}
+// ElementEnabled is a query option to wait until the element is enabled.
+func ElementEnabled(s *Selector) {
+	WaitFunc(s.waitReady(Enabled))(s)
+}
 
-			// check if hidden
-			for _, c := range style {
-				switch c.Name {
-				case "display":
-					//log.Printf("%d >>>> %s=%s", n.NodeID, c.Name, c.Value)
-					hidden = c.Value == "none"
+// ElementSelected is a query option to wait until the element is selected.
+func ElementSelected(s *Selector) {
+	WaitFunc(s.waitReady(Selected))(s)
+}
 
-				case "visibility":
-					//log.Printf("%d >>>> %s=%s", n.NodeID, c.Name, c.Value)
-					hidden = c.Value != "visible"
+// WaitCondition is a query option to wait until the elements satisfy cond
+// (ie, a combination of conditions built with And, Or and Not).
+func WaitCondition(cond Condition) QueryOption {
+	return func(s *Selector) {
+		WaitFunc(s.waitReady(cond))(s)
+	}
+}
 
-				case "hidden":
-					//log.Printf("%d >>>> %s=%s", n.NodeID, c.Name, c.Value)
-					hidden = true
-				}
+// AtLeast is a query option to wait until at least n elements are returned
+// from the query selector.
+func AtLeast(n int) QueryOption {
+	return func(s *Selector) {
+		s.exp = n
+	}
+}
 
-				if hidden {
-					return nil
-				}
-			}
+// AtMost is a query option to wait until at most n elements are returned
+// from the query selector. The expected minimum number of elements is
+// lowered to n when it exceeds n.
+func AtMost(n int) QueryOption {
+	return func(s *Selector) {
+		s.max = n
+		if s.exp > n {
+			s.exp = n
 		}
-
-		return ErrVisible
-	}))(s)
+	}
 }
 
-// ElementEnabled is a query option to wait until the element is enabled.
-func ElementEnabled(s *Selector) {
-	WaitFunc(s.waitReady(func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
-		n.RLock()
-		defer n.RUnlock()
+// Exactly is a query option to wait until exactly n elements are returned
+// from the query selector.
+func Exactly(n int) QueryOption {
+	return func(s *Selector) {
+		s.exp, s.max = n, n
+	}
+}
 
-		for i := 0; i < len(n.Attributes); i += 2 {
-			if n.Attributes[i] == "disabled" {
-				return ErrDisabled
-			}
-		}
+// QueryTimeout is a query option to specify the time to wait for the query to
+// complete, overriding the default set with WithQueryTimeout.
+func QueryTimeout(d time.Duration) QueryOption {
+	return func(s *Selector) {
+		s.timeout = d
+	}
+}
 
-		return nil
-	}))(s)
+// QueryPollInterval is a query option to specify the interval the query
+// re-checks conditions that are not reported by DOM events, overriding the
+// default set with WithPollInterval.
+func QueryPollInterval(d time.Duration) QueryOption {
+	return func(s *Selector) {
+		s.interval = d
+	}
 }
 
-// ElementSelected is a query option to wait until the element is selected.
-func ElementSelected(s *Selector) {
-	WaitFunc(s.waitReady(func(ctxt context.Context, h cdp.FrameHandler, n *cdp.Node) error {
-		n.RLock()
-		defer n.RUnlock()
+// FromNode is a query option to run the selector relative to node (ie, a
+// node previously retrieved with Nodes), only matching its descendants.
+//
+// The query fails with ErrInvalidated if the node's document is replaced.
+func FromNode(node *cdp.Node) QueryOption {
+	return func(s *Selector) {
+		s.from = node
+	}
+}
 
-		for i := 0; i < len(n.Attributes); i += 2 {
-			if n.Attributes[i] == "selected" {
-				return nil
-			}
-		}
+// Actionable is a query option for input actions (ie, Click, DoubleClick,
+// Hover and SendKeys) to scroll the first element into view, and wait until it
+// is enabled and no longer moving, before performing the action. The action
+// fails with an *InterceptedError if another element (ie, an overlay) would
+// receive the input instead of the element or one of its descendants.
+func Actionable(s *Selector) {
+	s.actionable = true
+}
 
-		return ErrNotSelected
-	}))(s)
+// KeyDelay is a query option to set the time SendKeys waits after each typed
+// key. Defaults to DefaultKeyDelay.
+func KeyDelay(d time.Duration) QueryOption {
+	return func(s *Selector) {
+		s.keyDelay = d
+	}
 }
 
-// AtLeast is a query option to wait until at least n elements are returned
-// from the query selector.
-func AtLeast(n int) QueryOption {
+// KeyOptions is a query option to pass key action options (ie, KeyModifiers)
+// to SendKeys.
+func KeyOptions(opts ...KeyOption) QueryOption {
 	return func(s *Selector) {
-		s.exp = n
+		s.keyOpts = append(s.keyOpts, opts...)
 	}
 }
 
@@ -463,3 +804,62 @@ func WaitEnabled(sel interface{}, opts ...QueryOption) Action {
 func WaitSelected(sel interface{}, opts ...QueryOption) Action {
 	return Query(sel, append(opts, ElementSelected)...)
 }
+
+// WaitNotPresent waits until the selector no longer matches any element (ie,
+// the element was removed from the DOM).
+func WaitNotPresent(sel interface{}, opts ...QueryOption) Action {
+	return Query(sel, append(opts, Exactly(0))...)
+}
+
+const (
+	// textMatchJS returns the innermost element below this, with the tag
+	// name tag, whose visible text matches text using mode.
+	textMatchJS = `function(text, mode, normalize, tag) {
+		var norm = function(s) {
+			s = s || '';
+			return (normalize ? s.replace(/\s+/g, ' ') : s).trim();
+		};
+		var re = mode === 'regexp' ? new RegExp(text) : null;
+		if (!re) {
+			text = norm(text);
+		}
+		var match = function(el) {
+			var t = norm(el.innerText !== undefined ? el.innerText : el.textContent);
+			switch (mode) {
+			case 'exact':
+				return t === text;
+			case 'contains':
+				return t.indexOf(text) !== -1;
+			}
+			return re.test(t);
+		};
+		var a = this.querySelectorAll(tag), res = [];
+		for (var i = 0; i < a.length; i++) {
+			if (match(a[i])) {
+				res.push(a[i]);
+			}
+		}
+		for (var i = 0; i < res.length; i++) {
+			var inner = res.some(function(el) {
+				return el !== res[i] && res[i].contains(el);
+			});
+			if (!inner) {
+				return [res[i]];
+			}
+		}
+		return [];
+	}`
+
+	// linkTextJS returns the first anchor element below this whose rendered
+	// text is equal to (or contains, when partial) text.
+	linkTextJS = `function(text, partial) {
+		var a = this.querySelectorAll('a');
+		for (var i = 0; i < a.length; i++) {
+			var t = (a[i].innerText || '').trim();
+			if (partial ? t.indexOf(text) !== -1 : t === text) {
+				return [a[i]];
+			}
+		}
+		return [];
+	}`
+)
@@ -0,0 +1,193 @@
+package chromedp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/knq/chromedp/cdp/target"
+	"github.com/knq/chromedp/client"
+)
+
+var (
+	_ client.Transport = &session{}
+)
+
+// session is a client.Transport for a target attached through a browser
+// level connection, where messages are sent with Target.sendMessageToTarget
+// and received through Target.receivedMessageFromTarget events.
+type session struct {
+	b  *Browser
+	id target.ID
+
+	// ctxt is the context the session was attached with.
+	ctxt context.Context
+
+	// in receives incoming messages from the browser connection.
+	in chan interface{}
+
+	// out receives the queued incoming messages.
+	out chan interface{}
+
+	// w receives outgoing messages, which are queued and sent in order by a
+	// separate goroutine, so that writers do not wait on the browser.
+	w chan interface{}
+
+	// closed is closed when the session is detached.
+	closed chan struct{}
+	once   sync.Once
+}
+
+// Attach attaches to the target with the specified id, returning a transport
+// for the target that is multiplexed over the browser connection.
+//
+// The transport is detached when the passed context is done, or when Close
+// is called.
+func (b *Browser) Attach(ctxt context.Context, id target.ID) (client.Transport, error) {
+	s := &session{
+		b:      b,
+		id:     id,
+		ctxt:   ctxt,
+		in:     make(chan interface{}),
+		out:    make(chan interface{}),
+		w:      make(chan interface{}),
+		closed: make(chan struct{}),
+	}
+
+	// register prior to attaching, so that no messages are lost
+	b.Lock()
+	if b.sessions == nil {
+		b.sessions = make(map[target.ID]*session)
+	}
+	if _, ok := b.sessions[id]; ok {
+		b.Unlock()
+		return nil, fmt.Errorf("already attached to target %s", id)
+	}
+	b.sessions[id] = s
+	b.Unlock()
+
+	// queue incoming messages until the session is closed or the context is
+	// done, so that the browser connection is never blocked by a slow reader
+	qctxt, cancel := context.WithCancel(ctxt)
+	go forward(qctxt, s.in, s.out)
+	writes := make(chan interface{})
+	go forward(qctxt, s.w, writes)
+	go s.write(writes)
+	go func() {
+		defer cancel()
+
+		select {
+		case <-s.closed:
+		case <-ctxt.Done():
+			b.removeSession(s)
+		}
+	}()
+
+	ok, err := target.AttachToTarget(id).Do(ctxt, b)
+	if err == nil && !ok {
+		err = fmt.Errorf("could not attach to target %s", id)
+	}
+	if err != nil {
+		b.removeSession(s)
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// detached removes and closes the session for the target with the specified
+// id.
+func (b *Browser) detached(id target.ID) {
+	b.RLock()
+	s, ok := b.sessions[id]
+	b.RUnlock()
+
+	if ok {
+		b.removeSession(s)
+	}
+}
+
+// removeSession removes and closes the session.
+func (b *Browser) removeSession(s *session) {
+	b.Lock()
+	if b.sessions[s.id] == s {
+		delete(b.sessions, s.id)
+	}
+	b.Unlock()
+
+	s.once.Do(func() {
+		close(s.closed)
+	})
+}
+
+// received passes an incoming message to the session for the target with
+// the specified id.
+func (b *Browser) received(id target.ID, msg string) {
+	b.RLock()
+	s, ok := b.sessions[id]
+	b.RUnlock()
+
+	if !ok {
+		return
+	}
+
+	select {
+	case s.in <- []byte(msg):
+	case <-s.closed:
+	}
+}
+
+// Read reads the next message received from the target.
+func (s *session) Read() ([]byte, error) {
+	select {
+	case buf, ok := <-s.out:
+		if ok {
+			return buf.([]byte), nil
+		}
+
+	case <-s.closed:
+	}
+
+	return nil, io.EOF
+}
+
+// Write queues a message to be sent to the target, without waiting for the
+// browser to acknowledge it.
+func (s *session) Write(buf []byte) error {
+	select {
+	case s.w <- buf:
+		return nil
+	case <-s.closed:
+		return io.ErrClosedPipe
+	}
+}
+
+// write sends the queued outgoing messages to the target, in order, until
+// writes is closed. The session is closed if a message cannot be sent, as its
+// reply would otherwise never be received.
+func (s *session) write(writes <-chan interface{}) {
+	for buf := range writes {
+		err := target.SendMessageToTarget(s.id.String(), string(buf.([]byte))).Do(s.ctxt, s.b)
+		if err != nil {
+			log.Printf("error: could not send message to target %s, got: %v", s.id, err)
+			s.b.removeSession(s)
+			return
+		}
+	}
+}
+
+// Close detaches from the target.
+func (s *session) Close() error {
+	select {
+	case <-s.closed:
+		return nil
+	default:
+	}
+
+	err := target.DetachFromTarget(s.id).Do(s.ctxt, s.b)
+	s.b.removeSession(s)
+
+	return err
+}
@@ -0,0 +1,70 @@
+package chromedp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/knq/chromedp/cdp"
+)
+
+// Target is a handle to a single Chrome target managed by a CDP, that runs
+// actions against that target without changing the CDP's active target.
+//
+// Multiple goroutines can each use their own Target to concurrently drive
+// different targets of the same Chrome instance.
+type Target struct {
+	c  *CDP
+	id string
+}
+
+// Target returns a handle for the target with the specified id.
+func (c *CDP) Target(id string) (*Target, error) {
+	c.RLock()
+	defer c.RUnlock()
+
+	if _, ok := c.handlerMap[id]; !ok {
+		return nil, fmt.Errorf("no handler associated with target id %s", id)
+	}
+
+	return &Target{c: c, id: id}, nil
+}
+
+// TargetByIndex returns a handle for the target with the specified index.
+func (c *CDP) TargetByIndex(i int) (*Target, error) {
+	c.RLock()
+	defer c.RUnlock()
+
+	if i < 0 || i >= len(c.handlers) {
+		return nil, fmt.Errorf("no handler associated with target index %d", i)
+	}
+
+	return &Target{c: c, id: c.handlers[i].GetTarget().GetID()}, nil
+}
+
+// ID returns the target's id.
+func (t *Target) ID() string {
+	return t.id
+}
+
+// Run executes the action against the target using the supplied context.
+//
+// Returns an error if the target has been closed.
+func (t *Target) Run(ctxt context.Context, a Action) error {
+	h := t.c.GetHandlerByID(t.id)
+	if h == nil {
+		return fmt.Errorf("no handler associated with target id %s", t.id)
+	}
+
+	return a.Do(ctxt, h)
+}
+
+// Listen returns a channel that receives the target's events for the
+// specified event types. See TargetHandler.Listen.
+func (t *Target) Listen(ctxt context.Context, eventTypes ...cdp.MethodType) (<-chan interface{}, error) {
+	h := t.c.GetHandlerByID(t.id)
+	if h == nil {
+		return nil, fmt.Errorf("no handler associated with target id %s", t.id)
+	}
+
+	return h.Listen(ctxt, eventTypes...), nil
+}
@@ -1,6 +1,9 @@
 package chromedp
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/knq/chromedp/cdp"
 	"github.com/knq/chromedp/cdp/util"
 )
@@ -18,14 +21,56 @@ func UnmarshalMessage(msg *cdp.Message) (interface{}, error) {
 	return util.UnmarshalMessage(msg)
 }
 
+// forward forwards values from in to out, queueing values so that senders are
+// never blocked by a slow receiver. out is closed when the context is done.
+func forward(ctxt context.Context, in <-chan interface{}, out chan<- interface{}) {
+	defer close(out)
+
+	var queue []interface{}
+	for {
+		var next interface{}
+		var send chan<- interface{}
+		if len(queue) > 0 {
+			next, send = queue[0], out
+		}
+
+		select {
+		case v := <-in:
+			queue = append(queue, v)
+
+		case send <- next:
+			queue = queue[1:]
+
+		case <-ctxt.Done():
+			return
+		}
+	}
+}
+
+// drain discards all pending values on ch, without blocking.
+func drain(ch <-chan interface{}) {
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
 // FrameOp is a frame manipulation operation.
 type FrameOp func(*cdp.Frame)
 
-/*func domContentEventFired(f *cdp.Frame) {
+func domContentEventFired(f *cdp.Frame) {
+	setFrameState(f, cdp.FrameDOMContentEventFired)
 }
 
 func loadEventFired(f *cdp.Frame) {
-}*/
+	setFrameState(f, cdp.FrameLoadEventFired)
+}
 
 func frameAttached(id cdp.FrameID) FrameOp {
 	return func(f *cdp.Frame) {
@@ -34,9 +79,20 @@ func frameAttached(id cdp.FrameID) FrameOp {
 	}
 }
 
-/*func frameNavigated(f *cdp.Frame) {
-	setFrameState(f, cdp.FrameNavigated)
-}*/
+func frameNavigated(n *cdp.Frame) FrameOp {
+	return func(f *cdp.Frame) {
+		if f != n {
+			f.ParentID = n.ParentID
+			f.LoaderID = n.LoaderID
+			f.Name = n.Name
+			f.URL = n.URL
+			f.SecurityOrigin = n.SecurityOrigin
+			f.MimeType = n.MimeType
+		}
+		clearFrameState(f, cdp.FrameDOMContentEventFired|cdp.FrameLoadEventFired)
+		setFrameState(f, cdp.FrameNavigated)
+	}
+}
 
 func frameDetached(f *cdp.Frame) {
 	f.ParentID = emptyFrameID
@@ -260,3 +316,55 @@ func removeNode(n []*cdp.Node, id cdp.NodeID) []*cdp.Node {
 
 	return append(n[:i], n[i+1:]...)
 }
+
+// cssIdent escapes s for use as a CSS identifier (ie, an id or class name),
+// following the CSSOM rules for serializing an identifier.
+func cssIdent(s string) string {
+	var buf []byte
+	for i, r := range s {
+		switch {
+		case r == 0:
+			buf = append(buf, "\ufffd"...)
+
+		case r < 0x20 || r == 0x7f,
+			i == 0 && r >= '0' && r <= '9',
+			i == 1 && r >= '0' && r <= '9' && s[0] == '-':
+			buf = append(buf, fmt.Sprintf(`\%x `, r)...)
+
+		case i == 0 && r == '-' && len(s) == 1:
+			buf = append(buf, `\-`...)
+
+		case r >= 0x80, r == '-', r == '_',
+			r >= '0' && r <= '9', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			buf = append(buf, string(r)...)
+
+		default:
+			buf = append(buf, '\\')
+			buf = append(buf, string(r)...)
+		}
+	}
+
+	return string(buf)
+}
+
+// cssString quotes s for use as a CSS string (ie, an attribute value).
+func cssString(s string) string {
+	buf := []byte{'"'}
+	for _, r := range s {
+		switch {
+		case r == 0:
+			buf = append(buf, "\ufffd"...)
+
+		case r < 0x20 || r == 0x7f:
+			buf = append(buf, fmt.Sprintf(`\%x `, r)...)
+
+		case r == '"' || r == '\\':
+			buf = append(buf, '\\', byte(r))
+
+		default:
+			buf = append(buf, string(r)...)
+		}
+	}
+
+	return string(append(buf, '"'))
+}
@@ -0,0 +1,55 @@
+package chromedp
+
+import "testing"
+
+func TestCSSIdent(t *testing.T) {
+	tests := []struct {
+		s, exp string
+	}{
+		{"", ""},
+		{"foo-bar_1", "foo-bar_1"},
+		{"é", "é"},
+		{"1a", `\31 a`},
+		{"-1a", `-\31 a`},
+		{"--a", "--a"},
+		{"-", `\-`},
+		{"a1", "a1"},
+		{"a\nb", `a\a b`},
+		{"a\x7f", `a\7f `},
+		{"a\x00b", "a\ufffdb"},
+		{"a b", `a\ b`},
+		{`a"b`, `a\"b`},
+		{`a'b`, `a\'b`},
+		{`a\b`, `a\\b`},
+		{"a.b#c", `a\.b\#c`},
+	}
+
+	for i, test := range tests {
+		if s := cssIdent(test.s); s != test.exp {
+			t.Errorf("test %d cssIdent(%q) expected %q, got: %q", i, test.s, test.exp, s)
+		}
+	}
+}
+
+func TestCSSString(t *testing.T) {
+	tests := []struct {
+		s, exp string
+	}{
+		{"", `""`},
+		{"foo", `"foo"`},
+		{"1a", `"1a"`},
+		{"it's", `"it's"`},
+		{`a"b`, `"a\"b"`},
+		{`a\b`, `"a\\b"`},
+		{"a\nb", `"a\a b"`},
+		{"a\tb", `"a\9 b"`},
+		{"a\x00b", "\"a\ufffdb\""},
+		{"é", `"é"`},
+	}
+
+	for i, test := range tests {
+		if s := cssString(test.s); s != test.exp {
+			t.Errorf("test %d cssString(%q) expected %q, got: %q", i, test.s, test.exp, s)
+		}
+	}
+}